@@ -0,0 +1,192 @@
+// Package chunk splits documents into passages sized for archival memory.
+// Passages follow the document's structure: they break at markdown headings
+// and between paragraphs, and only split a paragraph that is too long on its
+// own.
+package chunk
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultSize is the default passage length in bytes, a few hundred tokens.
+const DefaultSize = 1200
+
+// Chunk is one passage of a document.
+type Chunk struct {
+	Text   string // the passage, with surrounding whitespace trimmed
+	Offset int    // byte offset of Text in the document
+}
+
+// block is a run of the document that is never split across chunks unless
+// it is longer than the chunk size on its own: a paragraph, a heading line or
+// a fenced code block.
+type block struct {
+	start, end int
+	heading    bool
+}
+
+// Markdown splits a markdown document into chunks of up to size bytes. Every
+// heading starts a new chunk, consecutive headings staying together with the
+// text under them, and fenced code blocks are kept whole where they fit.
+func Markdown(doc string, size int) []Chunk {
+	return pack(doc, blocks(doc, true), size)
+}
+
+// Text splits plain text into chunks of up to size bytes at paragraph
+// breaks.
+func Text(doc string, size int) []Chunk {
+	return pack(doc, blocks(doc, false), size)
+}
+
+// blocks scans doc line by line into paragraphs, plus headings and fenced
+// code blocks when markdown is set.
+func blocks(doc string, markdown bool) []block {
+	var out []block
+	cur := block{start: -1}
+	flush := func() {
+		if cur.start >= 0 {
+			out = append(out, cur)
+		}
+		cur = block{start: -1}
+	}
+
+	fence := ""
+	for pos := 0; pos < len(doc); {
+		end := strings.IndexByte(doc[pos:], '\n')
+		if end < 0 {
+			end = len(doc)
+		} else {
+			end += pos + 1
+		}
+		line := strings.TrimSpace(doc[pos:end])
+
+		switch {
+		case fence != "":
+			// Inside a code fence, blank lines and headings are content.
+			cur.end = end
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+				flush()
+			}
+		case markdown && (strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")):
+			flush()
+			fence = line[:3]
+			cur = block{start: pos, end: end}
+		case line == "":
+			flush()
+		case markdown && isHeading(line):
+			flush()
+			out = append(out, block{start: pos, end: end, heading: true})
+		default:
+			if cur.start < 0 {
+				cur.start = pos
+			}
+			cur.end = end
+		}
+		pos = end
+	}
+	flush()
+	return out
+}
+
+// isHeading reports whether a trimmed line is an ATX heading ("## Title").
+func isHeading(line string) bool {
+	n := 0
+	for n < len(line) && line[n] == '#' {
+		n++
+	}
+	return n >= 1 && n <= 6 && (n == len(line) || line[n] == ' ' || line[n] == '\t')
+}
+
+// pack groups consecutive blocks into chunks of up to size bytes.
+func pack(doc string, bs []block, size int) []Chunk {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	var out []Chunk
+	start, end := -1, -1
+	onlyHeadings := true
+	flush := func() {
+		if start >= 0 {
+			out = appendChunk(out, doc, start, end)
+		}
+		start, end, onlyHeadings = -1, -1, true
+	}
+
+	for _, b := range bs {
+		if b.heading && !onlyHeadings {
+			flush()
+		}
+		if start >= 0 && !onlyHeadings && b.end-start > size {
+			flush()
+		}
+		from := b.start
+		if start >= 0 {
+			from = start // headings waiting for their text
+		}
+		if b.end-from > size {
+			// Too long for any chunk: split it on its own, keeping any
+			// headings just before it with its first piece.
+			for _, piece := range split(doc, from, b.end, size) {
+				out = appendChunk(out, doc, piece[0], piece[1])
+			}
+			start, end, onlyHeadings = -1, -1, true
+			continue
+		}
+		if start < 0 {
+			start = b.start
+		}
+		end = b.end
+		onlyHeadings = onlyHeadings && b.heading
+	}
+	flush()
+	return out
+}
+
+// appendChunk adds doc[start:end], trimmed, unless it is blank.
+func appendChunk(out []Chunk, doc string, start, end int) []Chunk {
+	text := doc[start:end]
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	offset := start + len(text) - len(trimmed)
+	trimmed = strings.TrimRight(trimmed, " \t\r\n")
+	if trimmed == "" {
+		return out
+	}
+	return append(out, Chunk{Text: trimmed, Offset: offset})
+}
+
+// split breaks doc[start:end] into ranges of up to size bytes, preferring
+// to end each at a line break, then after a sentence, then between words.
+func split(doc string, start, end, size int) [][2]int {
+	var out [][2]int
+	for end-start > size {
+		cut := start + size
+		for !utf8.RuneStart(doc[cut]) {
+			cut--
+		}
+		window := doc[start:cut]
+		if i := strings.LastIndexByte(window, '\n'); i > size/2 {
+			cut = start + i + 1
+		} else if i := lastSentenceEnd(window); i > size/2 {
+			cut = start + i
+		} else if i := strings.LastIndexByte(window, ' '); i > size/2 {
+			cut = start + i + 1
+		}
+		out = append(out, [2]int{start, cut})
+		start = cut
+	}
+	return append(out, [2]int{start, end})
+}
+
+// lastSentenceEnd returns the index just after the last ". ", "! " or "? "
+// in s, or -1.
+func lastSentenceEnd(s string) int {
+	best := -1
+	for _, sep := range []string{". ", "! ", "? "} {
+		if i := strings.LastIndex(s, sep); i >= 0 && i+2 > best {
+			best = i + 2
+		}
+	}
+	return best
+}
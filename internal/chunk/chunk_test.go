@@ -0,0 +1,80 @@
+package chunk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown_SplitsAtHeadings(t *testing.T) {
+	doc := `# Project
+
+## Install
+
+Run go install.
+
+## Usage
+
+Call botmem init first.
+
+Then add memories.
+`
+	chunks := Markdown(doc, 1000)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d: %q", len(chunks), chunks)
+	}
+	if chunks[0].Text != "# Project\n\n## Install\n\nRun go install." {
+		t.Errorf("expected consecutive headings kept with their text, got %q", chunks[0].Text)
+	}
+	if !strings.HasPrefix(chunks[1].Text, "## Usage") || !strings.HasSuffix(chunks[1].Text, "Then add memories.") {
+		t.Errorf("unexpected second chunk %q", chunks[1].Text)
+	}
+	for _, c := range chunks {
+		if doc[c.Offset:c.Offset+len(c.Text)] != c.Text {
+			t.Errorf("offset %d does not point at %q", c.Offset, c.Text)
+		}
+	}
+}
+
+func TestMarkdown_KeepsCodeFencesWhole(t *testing.T) {
+	doc := "## Example\n\n```\n# not a heading\n\nstill code\n```\n\nAfter."
+	chunks := Markdown(doc, 1000)
+	if len(chunks) != 1 {
+		t.Fatalf("expected the fence to stay in one chunk, got %q", chunks)
+	}
+}
+
+func TestText_PacksParagraphsUpToSize(t *testing.T) {
+	para := strings.Repeat("word ", 20) // 100 bytes
+	doc := strings.Repeat(para+"\n\n", 5)
+	chunks := Text(doc, 250)
+	if len(chunks) != 3 {
+		t.Fatalf("expected paragraphs packed two to a chunk, got %d", len(chunks))
+	}
+	for _, c := range chunks {
+		if len(c.Text) > 250 {
+			t.Errorf("chunk longer than the size: %d bytes", len(c.Text))
+		}
+	}
+	if got := Text("# heading\n\nbody", 100); len(got) != 1 {
+		t.Errorf("expected plain text to ignore markdown headings, got %q", got)
+	}
+}
+
+func TestSplit_LongParagraph(t *testing.T) {
+	doc := strings.Repeat("This is a sentence about memory. ", 40)
+	chunks := Text(doc, 200)
+	if len(chunks) < 6 {
+		t.Fatalf("expected the paragraph split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks {
+		if len(c.Text) > 200 {
+			t.Errorf("chunk longer than the size: %d bytes", len(c.Text))
+		}
+		if !strings.HasSuffix(c.Text, ".") {
+			t.Errorf("expected a split at a sentence end, got %q", c.Text)
+		}
+		if doc[c.Offset:c.Offset+len(c.Text)] != c.Text {
+			t.Errorf("offset %d does not point at %q", c.Offset, c.Text)
+		}
+	}
+}
@@ -11,19 +11,61 @@ import (
 type Config struct {
 	LLM        LLMConfig        `yaml:"llm"`
 	Embeddings EmbeddingsConfig `yaml:"embeddings"`
+	Search     SearchConfig     `yaml:"search"`
+	Forget     ForgetConfig     `yaml:"forget,omitempty"`
 }
 
 type LLMConfig struct {
-	Provider string `yaml:"provider"` // "anthropic", "ollama"
+	Provider string `yaml:"provider"` // "claude", "anthropic", "ollama", "openai"
 	Model    string `yaml:"model"`    // e.g. "claude-sonnet-4-20250514", "llama3.2"
-	APIKey   string `yaml:"api_key"`  // for anthropic
-	BaseURL  string `yaml:"base_url"` // for ollama, default http://localhost:11434
+	APIKey   string `yaml:"api_key"`  // for anthropic, and openai servers that need one
+	BaseURL  string `yaml:"base_url"` // for ollama (default http://localhost:11434) or openai (e.g. http://localhost:8080/v1)
 }
 
 type EmbeddingsConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	Model   string `yaml:"model"`   // e.g. "nomic-embed-text"
-	BaseURL string `yaml:"base_url"`
+	Enabled    bool   `yaml:"enabled"`
+	Provider   string `yaml:"provider,omitempty"` // "ollama" (default), "openai" or "local"
+	Model      string `yaml:"model"`              // e.g. "nomic-embed-text"
+	BaseURL    string `yaml:"base_url"`
+	APIKey     string `yaml:"api_key,omitempty"`    // for openai servers that need one
+	Dimensions int    `yaml:"dimensions,omitempty"` // local: vector size (default 384); openai: requested size, if the model supports it
+
+	// Quantization is how new embeddings are stored: "float32" (default),
+	// "int8" or "binary". Convert existing ones with 'botmem embeddings quantize'.
+	Quantization string `yaml:"quantization,omitempty"`
+}
+
+// SearchConfig tunes archival search. Zero values use the defaults (equal
+// weights, RRF constant 60, no recency weighting).
+type SearchConfig struct {
+	LexicalWeight  float64 `yaml:"lexical_weight,omitempty"`
+	SemanticWeight float64 `yaml:"semantic_weight,omitempty"`
+	RRFK           float64 `yaml:"rrf_k,omitempty"`
+
+	// ContentWeight and TagsWeight weight full-text (BM25) matches in an
+	// entry's content and tags; both default to 1.
+	ContentWeight float64 `yaml:"content_weight,omitempty"`
+	TagsWeight    float64 `yaml:"tags_weight,omitempty"`
+
+	// RecencyWeight (0–1) blends each result's age into its score, halving
+	// the age factor every RecencyHalfLife (e.g. "30d"; default 30 days).
+	RecencyWeight   float64 `yaml:"recency_weight,omitempty"`
+	RecencyHalfLife string  `yaml:"recency_half_life,omitempty"`
+}
+
+// ForgetConfig holds the named policies 'botmem forget --policy' applies.
+// A policy named "default" is used when none is given; fields left empty
+// take the built-in default policy's values.
+type ForgetConfig struct {
+	Policies map[string]ForgetPolicyConfig `yaml:"policies,omitempty"`
+}
+
+type ForgetPolicyConfig struct {
+	Action    string  `yaml:"action,omitempty"`    // "archive" (default) or "delete"
+	Curve     string  `yaml:"curve,omitempty"`     // "exponential" (default) or "linear"
+	HalfLife  string  `yaml:"half_life,omitempty"` // e.g. "90d" (default)
+	Threshold float64 `yaml:"threshold,omitempty"` // retention below which memories are forgotten (default 0.1)
+	MinIdle   string  `yaml:"min_idle,omitempty"`  // never forget memories accessed more recently (default "30d")
 }
 
 // UnmarshalYAML handles "embeddings: false" (bool) as well as the full object form.
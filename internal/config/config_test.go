@@ -114,3 +114,20 @@ func TestSave_CreatesDirectory(t *testing.T) {
 		t.Error("file not created")
 	}
 }
+
+func TestLoad_SearchWeights(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte("search:\n  lexical_weight: 0.3\n  semantic_weight: 0.7\n"), 0600)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Search.LexicalWeight != 0.3 || cfg.Search.SemanticWeight != 0.7 {
+		t.Errorf("unexpected weights: %+v", cfg.Search)
+	}
+	if cfg.Search.RRFK != 0 {
+		t.Errorf("expected unset rrf_k to be 0, got %v", cfg.Search.RRFK)
+	}
+}
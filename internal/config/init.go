@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	"github.com/stukennedy/botmem/internal/embeddings"
 )
 
 // RunInit walks the user through setting up botmem.
@@ -40,13 +42,19 @@ func RunInit(in io.Reader, out io.Writer) (*Config, error) {
 	fmt.Fprintln(out, "    1) Claude Code (uses claude -p — recommended)")
 	fmt.Fprintln(out, "    2) Anthropic   (Claude API — requires API key)")
 	fmt.Fprintln(out, "    3) Ollama      (local models — requires Ollama running)")
+	fmt.Fprintln(out, "    4) OpenAI-compatible (llama.cpp server, vLLM, LM Studio, OpenAI)")
 	fmt.Fprintln(out, "")
 
-	choice := prompt("  Choose (1, 2, or 3)", "1")
+	choice := prompt("  Choose (1, 2, 3, or 4)", "1")
 
 	cfg := &Config{}
 
 	switch choice {
+	case "4":
+		cfg.LLM.Provider = "openai"
+		cfg.LLM.BaseURL = prompt("  Server URL", "http://localhost:8080/v1")
+		cfg.LLM.Model = prompt("  Model (leave empty if the server hosts one model)", "")
+		cfg.LLM.APIKey = prompt("  API key (leave empty if none, or to use OPENAI_API_KEY)", "")
 	case "3":
 		cfg.LLM.Provider = "ollama"
 		cfg.LLM.BaseURL = prompt("  Ollama URL", "http://localhost:11434")
@@ -82,14 +90,32 @@ func RunInit(in io.Reader, out io.Writer) (*Config, error) {
 	fmt.Fprintln(out, "")
 	fmt.Fprintln(out, "  Embeddings enable semantic search (finding memories by meaning).")
 	fmt.Fprintln(out, "  This is optional — keyword search (FTS5) works without it.")
-	fmt.Fprintln(out, "  Embeddings require Ollama running locally.")
+	fmt.Fprintln(out, "  Use Ollama, an OpenAI-compatible embeddings server, or the built-in")
+	fmt.Fprintln(out, "  offline embedder (no server needed).")
 	fmt.Fprintln(out, "")
 
 	enableEmb := prompt("  Enable embeddings? (y/n)", "n")
 	if strings.ToLower(enableEmb) == "y" {
 		cfg.Embeddings.Enabled = true
-		cfg.Embeddings.BaseURL = prompt("  Ollama URL for embeddings", "http://localhost:11434")
-		cfg.Embeddings.Model = prompt("  Embedding model", "nomic-embed-text")
+		fmt.Fprintln(out, "")
+		fmt.Fprintln(out, "    1) Ollama")
+		fmt.Fprintln(out, "    2) OpenAI-compatible (text-embeddings-inference, llama.cpp, LocalAI, OpenAI)")
+		fmt.Fprintln(out, "    3) Built-in (offline, no server — lexical similarity only)")
+		fmt.Fprintln(out, "")
+		switch prompt("  Embeddings provider (1, 2, or 3)", "1") {
+		case "3":
+			cfg.Embeddings.Provider = "local"
+			cfg.Embeddings.Model = embeddings.LocalModel
+		case "2":
+			cfg.Embeddings.Provider = "openai"
+			cfg.Embeddings.BaseURL = prompt("  Embeddings server URL", "http://localhost:8080/v1")
+			cfg.Embeddings.Model = prompt("  Embedding model (leave empty if the server hosts one model)", "")
+			cfg.Embeddings.APIKey = prompt("  API key (leave empty if none, or to use OPENAI_API_KEY)", "")
+		default:
+			cfg.Embeddings.Provider = "ollama"
+			cfg.Embeddings.BaseURL = prompt("  Ollama URL for embeddings", "http://localhost:11434")
+			cfg.Embeddings.Model = prompt("  Embedding model", "nomic-embed-text")
+		}
 	}
 
 	// Save
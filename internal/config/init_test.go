@@ -112,3 +112,75 @@ func TestRunInit_DefaultsToClaudeCode(t *testing.T) {
 		t.Errorf("expected claude as default, got %q", cfg.LLM.Provider)
 	}
 }
+
+func TestRunInit_OpenAI(t *testing.T) {
+	dir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", origHome)
+
+	// Choose OpenAI-compatible (4), custom URL, model, no key, no embeddings
+	input := "4\nhttp://localhost:1234/v1\nqwen2.5-7b-instruct\n\nn\n"
+	var out bytes.Buffer
+
+	cfg, err := RunInit(strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	if cfg.LLM.Provider != "openai" {
+		t.Errorf("expected openai, got %q", cfg.LLM.Provider)
+	}
+	if cfg.LLM.BaseURL != "http://localhost:1234/v1" {
+		t.Errorf("expected custom URL, got %q", cfg.LLM.BaseURL)
+	}
+	if cfg.LLM.Model != "qwen2.5-7b-instruct" {
+		t.Errorf("expected custom model, got %q", cfg.LLM.Model)
+	}
+	if cfg.LLM.APIKey != "" {
+		t.Errorf("expected no API key, got %q", cfg.LLM.APIKey)
+	}
+}
+
+func TestRunInit_OpenAIEmbeddings(t *testing.T) {
+	dir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", origHome)
+
+	// Claude Code, then OpenAI-compatible embeddings (2) with a TEI URL and key
+	input := "1\ny\n2\nhttp://localhost:3000\nbge-small\nsk-emb\n"
+	var out bytes.Buffer
+
+	cfg, err := RunInit(strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	e := cfg.Embeddings
+	if !e.Enabled || e.Provider != "openai" {
+		t.Fatalf("expected openai embeddings enabled, got %+v", e)
+	}
+	if e.BaseURL != "http://localhost:3000" || e.Model != "bge-small" || e.APIKey != "sk-emb" {
+		t.Errorf("unexpected embeddings config: %+v", e)
+	}
+}
+
+func TestRunInit_LocalEmbeddings(t *testing.T) {
+	dir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", origHome)
+
+	// Claude Code, then built-in embeddings (3) — no further questions
+	input := "1\ny\n3\n"
+	var out bytes.Buffer
+
+	cfg, err := RunInit(strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if !cfg.Embeddings.Enabled || cfg.Embeddings.Provider != "local" || cfg.Embeddings.BaseURL != "" {
+		t.Errorf("expected local embeddings, got %+v", cfg.Embeddings)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/stukennedy/tooey/input"
 	"github.com/stukennedy/tooey/node"
 	"golang.org/x/term"
+
+	"github.com/stukennedy/botmem/internal/embeddings"
 )
 
 // TUI screen states
@@ -21,6 +23,9 @@ const (
 	screenAnthropicKey
 	screenOllamaURL
 	screenOllamaModel
+	screenOpenAIURL
+	screenOpenAIModel
+	screenOpenAIKey
 	screenEmbeddings
 	screenEmbeddingsURL
 	screenEmbeddingsModel
@@ -34,11 +39,14 @@ type tuiModel struct {
 	selected int // for list selections
 
 	// text inputs
-	apiKeyInput     component.TextInput
-	modelInput      component.TextInput
-	urlInput        component.TextInput
-	embURLInput     component.TextInput
-	embModelInput   component.TextInput
+	apiKeyInput      component.TextInput
+	modelInput       component.TextInput
+	urlInput         component.TextInput
+	embURLInput      component.TextInput
+	embModelInput    component.TextInput
+	openaiURLInput   component.TextInput
+	openaiModelInput component.TextInput
+	openaiKeyInput   component.TextInput
 
 	envKeyFound bool
 	useEnvKey   bool
@@ -66,13 +74,16 @@ func RunInitTUI() (*Config, error) {
 		Init: func() interface{} {
 			envKey := os.Getenv("ANTHROPIC_API_KEY")
 			return &tuiModel{
-				screen:        screenWelcome,
-				apiKeyInput:   component.NewTextInput("sk-ant-..."),
-				modelInput:    component.NewTextInput("claude-sonnet-4-20250514"),
-				urlInput:      component.NewTextInput("http://localhost:11434"),
-				embURLInput:   component.NewTextInput("http://localhost:11434"),
-				embModelInput: component.NewTextInput("nomic-embed-text"),
-				envKeyFound:   envKey != "",
+				screen:           screenWelcome,
+				apiKeyInput:      component.NewTextInput("sk-ant-..."),
+				modelInput:       component.NewTextInput("claude-sonnet-4-20250514"),
+				urlInput:         component.NewTextInput("http://localhost:11434"),
+				embURLInput:      component.NewTextInput("http://localhost:11434"),
+				embModelInput:    component.NewTextInput("nomic-embed-text"),
+				openaiURLInput:   component.NewTextInput("http://localhost:8080/v1"),
+				openaiModelInput: component.NewTextInput("qwen2.5-7b-instruct"),
+				openaiKeyInput:   component.NewTextInput("optional"),
+				envKeyFound:      envKey != "",
 			}
 		},
 		Update: func(m interface{}, msg app.Msg) app.UpdateResult {
@@ -102,7 +113,7 @@ func RunInitTUI() (*Config, error) {
 						mdl.selected--
 					}
 				case input.Down:
-					if mdl.selected < 2 {
+					if mdl.selected < 3 {
 						mdl.selected++
 					}
 				case input.Enter:
@@ -117,6 +128,9 @@ func RunInitTUI() (*Config, error) {
 					case 2:
 						mdl.cfg.LLM.Provider = "ollama"
 						mdl.screen = screenOllamaURL
+					case 3:
+						mdl.cfg.LLM.Provider = "openai"
+						mdl.screen = screenOpenAIURL
 					}
 					mdl.selected = 0
 				}
@@ -171,6 +185,35 @@ func RunInitTUI() (*Config, error) {
 					mdl.modelInput = mdl.modelInput.Update(km.Key)
 				}
 
+			case screenOpenAIURL:
+				if km.Key.Type == input.Enter {
+					val := strings.TrimSpace(mdl.openaiURLInput.Value)
+					if val == "" {
+						val = "http://localhost:8080/v1"
+					}
+					mdl.cfg.LLM.BaseURL = val
+					mdl.screen = screenOpenAIModel
+				} else {
+					mdl.openaiURLInput = mdl.openaiURLInput.Update(km.Key)
+				}
+
+			case screenOpenAIModel:
+				if km.Key.Type == input.Enter {
+					// Empty is fine for servers that host a single model.
+					mdl.cfg.LLM.Model = strings.TrimSpace(mdl.openaiModelInput.Value)
+					mdl.screen = screenOpenAIKey
+				} else {
+					mdl.openaiModelInput = mdl.openaiModelInput.Update(km.Key)
+				}
+
+			case screenOpenAIKey:
+				if km.Key.Type == input.Enter {
+					mdl.cfg.LLM.APIKey = strings.TrimSpace(mdl.openaiKeyInput.Value)
+					mdl.screen = screenEmbeddings
+				} else {
+					mdl.openaiKeyInput = mdl.openaiKeyInput.Update(km.Key)
+				}
+
 			case screenEmbeddings:
 				switch km.Key.Type {
 				case input.Up:
@@ -178,16 +221,30 @@ func RunInitTUI() (*Config, error) {
 						mdl.selected--
 					}
 				case input.Down:
-					if mdl.selected < 1 {
+					if mdl.selected < 3 {
 						mdl.selected++
 					}
 				case input.Enter:
-					if mdl.selected == 0 {
+					switch mdl.selected {
+					case 0:
 						// No embeddings
 						mdl.screen = screenConfirm
-					} else {
+					case 1:
+						mdl.cfg.Embeddings.Enabled = true
+						mdl.cfg.Embeddings.Provider = "ollama"
+						mdl.screen = screenEmbeddingsURL
+					case 2:
 						mdl.cfg.Embeddings.Enabled = true
+						mdl.cfg.Embeddings.Provider = "openai"
+						mdl.embURLInput = component.NewTextInput("http://localhost:8080/v1")
+						mdl.embModelInput = component.NewTextInput("(server default)")
 						mdl.screen = screenEmbeddingsURL
+					case 3:
+						// Built-in: nothing to configure
+						mdl.cfg.Embeddings.Enabled = true
+						mdl.cfg.Embeddings.Provider = "local"
+						mdl.cfg.Embeddings.Model = embeddings.LocalModel
+						mdl.screen = screenConfirm
 					}
 					mdl.selected = 0
 				}
@@ -197,6 +254,9 @@ func RunInitTUI() (*Config, error) {
 					val := strings.TrimSpace(mdl.embURLInput.Value)
 					if val == "" {
 						val = "http://localhost:11434"
+						if mdl.cfg.Embeddings.Provider == "openai" {
+							val = "http://localhost:8080/v1"
+						}
 					}
 					mdl.cfg.Embeddings.BaseURL = val
 					mdl.screen = screenEmbeddingsModel
@@ -207,7 +267,7 @@ func RunInitTUI() (*Config, error) {
 			case screenEmbeddingsModel:
 				if km.Key.Type == input.Enter {
 					val := strings.TrimSpace(mdl.embModelInput.Value)
-					if val == "" {
+					if val == "" && mdl.cfg.Embeddings.Provider == "ollama" {
 						val = "nomic-embed-text"
 					}
 					mdl.cfg.Embeddings.Model = val
@@ -280,7 +340,7 @@ func renderTUI(mdl *tuiModel, focused string) node.Node {
 	case screenProvider:
 		items := component.List{
 			Key:        "provider",
-			Items:      []string{"Claude Code (uses claude -p — recommended)", "Anthropic   (Claude API — requires key)", "Ollama      (local models — private)"},
+			Items:      []string{"Claude Code (uses claude -p — recommended)", "Anthropic   (Claude API — requires key)", "Ollama      (local models — private)", "OpenAI-compatible (llama.cpp, vLLM, LM Studio)"},
 			Selected:   mdl.selected,
 			FG:         node.Color(7),
 			SelectedFG: node.Color(0),
@@ -365,10 +425,55 @@ func renderTUI(mdl *tuiModel, focused string) node.Node {
 			node.Text(""),
 		)
 
+	case screenOpenAIURL:
+		content = node.Column(
+			node.Text(""),
+			node.TextStyled("  OpenAI-compatible Server URL", node.Color(2), 0, node.Bold),
+			node.Text(""),
+			node.TextStyled("  Where is the /v1/chat/completions server running?", node.Color(7), 0, 0),
+			node.TextStyled("  (Press Enter for default)", node.Color(8), 0, node.Italic),
+			node.Text(""),
+			mdl.openaiURLInput.Render("  URL: ", node.Color(7), 0),
+			node.Text(""),
+			node.Spacer(),
+			node.TextStyled("  Enter to confirm", node.Color(8), 0, 0),
+			node.Text(""),
+		)
+
+	case screenOpenAIModel:
+		content = node.Column(
+			node.Text(""),
+			node.TextStyled("  Model", node.Color(2), 0, node.Bold),
+			node.Text(""),
+			node.TextStyled("  Which model for memory extraction?", node.Color(7), 0, 0),
+			node.TextStyled("  (Leave empty if the server hosts a single model)", node.Color(8), 0, node.Italic),
+			node.Text(""),
+			mdl.openaiModelInput.Render("  Model: ", node.Color(7), 0),
+			node.Text(""),
+			node.Spacer(),
+			node.TextStyled("  Enter to confirm", node.Color(8), 0, 0),
+			node.Text(""),
+		)
+
+	case screenOpenAIKey:
+		content = node.Column(
+			node.Text(""),
+			node.TextStyled("  API Key", node.Color(2), 0, node.Bold),
+			node.Text(""),
+			node.TextStyled("  Leave blank for local servers, or to use", node.Color(7), 0, 0),
+			node.TextStyled("  the OPENAI_API_KEY environment variable.", node.Color(7), 0, 0),
+			node.Text(""),
+			mdl.openaiKeyInput.Render("  Key: ", node.Color(7), 0),
+			node.Text(""),
+			node.Spacer(),
+			node.TextStyled("  Enter to confirm", node.Color(8), 0, 0),
+			node.Text(""),
+		)
+
 	case screenEmbeddings:
 		items := component.List{
 			Key:        "embeddings",
-			Items:      []string{"Skip  (keyword search only — simpler)", "Enable (semantic search — requires Ollama)"},
+			Items:      []string{"Skip  (keyword search only — simpler)", "Enable (semantic search — Ollama)", "Enable (semantic search — OpenAI-compatible server)", "Enable (built-in — offline, no server)"},
 			Selected:   mdl.selected,
 			FG:         node.Color(7),
 			SelectedFG: node.Color(0),
@@ -379,7 +484,8 @@ func renderTUI(mdl *tuiModel, focused string) node.Node {
 			node.TextStyled("  Embeddings (Semantic Search)", node.Color(2), 0, node.Bold),
 			node.Text(""),
 			node.TextStyled("  Embeddings let you search memories by meaning,", node.Color(7), 0, 0),
-			node.TextStyled("  not just keywords. Requires Ollama locally.", node.Color(7), 0, 0),
+			node.TextStyled("  not just keywords. Requires Ollama or an", node.Color(7), 0, 0),
+			node.TextStyled("  OpenAI-compatible /v1/embeddings server.", node.Color(7), 0, 0),
 			node.TextStyled("  Keyword search (FTS5) works without this.", node.Color(8), 0, node.Italic),
 			node.Text(""),
 			items.Render(focused),
@@ -392,7 +498,7 @@ func renderTUI(mdl *tuiModel, focused string) node.Node {
 	case screenEmbeddingsURL:
 		content = node.Column(
 			node.Text(""),
-			node.TextStyled("  Embeddings — Ollama URL", node.Color(2), 0, node.Bold),
+			node.TextStyled("  Embeddings — Server URL", node.Color(2), 0, node.Bold),
 			node.Text(""),
 			mdl.embURLInput.Render("  URL: ", node.Color(7), 0),
 			node.Text(""),
@@ -433,9 +539,12 @@ func renderTUI(mdl *tuiModel, focused string) node.Node {
 		if mdl.cfg.LLM.Provider == "ollama" {
 			lines = append(lines, node.TextStyled(fmt.Sprintf("  Ollama URL:  %s", mdl.cfg.LLM.BaseURL), node.Color(7), 0, 0))
 		}
+		if mdl.cfg.LLM.Provider == "openai" {
+			lines = append(lines, node.TextStyled(fmt.Sprintf("  Server URL:  %s", mdl.cfg.LLM.BaseURL), node.Color(7), 0, 0))
+		}
 		embStatus := "disabled"
 		if mdl.cfg.Embeddings.Enabled {
-			embStatus = fmt.Sprintf("enabled (%s)", mdl.cfg.Embeddings.Model)
+			embStatus = fmt.Sprintf("enabled (%s %s)", mdl.cfg.Embeddings.Provider, mdl.cfg.Embeddings.Model)
 		}
 		lines = append(lines,
 			node.TextStyled(fmt.Sprintf("  Embeddings:  %s", embStatus), node.Color(7), 0, 0),
@@ -8,6 +8,10 @@ import (
 	"github.com/stukennedy/botmem/internal/memory"
 )
 
+// maxRelations caps the relations in a payload, so large graphs don't
+// flood the context window.
+const maxRelations = 50
+
 // Payload is the structured context returned to an LLM.
 type Payload struct {
 	CoreBlocks []*memory.Block    `json:"core_blocks"`
@@ -32,30 +36,20 @@ func Build(db *sql.DB) (*Payload, error) {
 		return nil, fmt.Errorf("load summaries: %w", err)
 	}
 
-	// Get all relations (for small graphs; paginate later if needed)
-	var allRelations []*memory.Relation
-	entities, err := graph.ListEntities("")
+	// Get the most important relations. Those included are shown to the
+	// agent, so they count as accessed; the rest don't.
+	relations, err := graph.ListRelations(maxRelations)
 	if err != nil {
-		return nil, fmt.Errorf("load entities: %w", err)
+		return nil, fmt.Errorf("load relations: %w", err)
 	}
-	seen := map[int64]bool{}
-	for _, e := range entities {
-		rels, err := graph.QueryEntity(e.Name)
-		if err != nil {
-			continue
-		}
-		for _, r := range rels {
-			if !seen[r.ID] {
-				seen[r.ID] = true
-				allRelations = append(allRelations, r)
-			}
-		}
+	if err := graph.Touch(relations...); err != nil {
+		return nil, err
 	}
 
 	return &Payload{
 		CoreBlocks: coreBlocks,
 		Summaries:  recentSummaries,
-		Graph:      allRelations,
+		Graph:      relations,
 	}, nil
 }
 
@@ -2,6 +2,7 @@ package context
 
 import (
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -82,3 +83,54 @@ func TestPayload_JSON(t *testing.T) {
 		t.Error("missing core_blocks key")
 	}
 }
+
+func TestBuild_ImportantRelationsFirst(t *testing.T) {
+	_, graph, _, dbPath := testSetup(t)
+	graph.AddRelation("Stuart", "works_on", "Moltbot", "")
+	id, _, _ := graph.EnsureRelation("Stuart", "born_in", "Glasgow", "")
+	graph.SetImportance(id, 0.9)
+
+	database, _ := db.Open(dbPath)
+	defer database.Close()
+
+	payload, err := Build(database)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if len(payload.Graph) != 2 || payload.Graph[0].Predicate != "born_in" {
+		t.Fatalf("expected the more important relation first, got %+v", payload.Graph)
+	}
+}
+
+func TestBuild_RecordsAccessToIncludedRelations(t *testing.T) {
+	_, graph, _, dbPath := testSetup(t)
+	for i := 0; i < maxRelations; i++ {
+		graph.AddRelation("Stuart", "knows", fmt.Sprintf("friend %d", i), "")
+	}
+	left, _, _ := graph.EnsureRelation("Stuart", "had", "toast", "")
+	graph.SetImportance(left, 0.1)
+
+	database, _ := db.Open(dbPath)
+	defer database.Close()
+
+	payload, err := Build(database)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if len(payload.Graph) != maxRelations {
+		t.Fatalf("expected %d relations, got %d", maxRelations, len(payload.Graph))
+	}
+	if r := payload.Graph[0]; r.AccessCount != 1 || r.LastAccessedAt == nil {
+		t.Errorf("expected the payload to show the access, got %+v", r.Access)
+	}
+	rels, _ := memory.NewGraphStore(database).ListRelations(maxRelations + 1)
+	for _, r := range rels {
+		want := 1
+		if r.ID == left {
+			want = 0
+		}
+		if r.AccessCount != want {
+			t.Errorf("%s %s: expected %d accesses, got %d", r.Predicate, r.Object, want, r.AccessCount)
+		}
+	}
+}
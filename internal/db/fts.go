@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Full-text tokenizers for archival search. Unicode61 splits on punctuation
+// and whitespace and folds case and diacritics; Porter adds English
+// stemming on top, so "contracts" finds "contract"; Trigram indexes every
+// three-character sequence, which matches substrings of words and text
+// without spaces, such as Chinese or Japanese, at the cost of a larger index
+// and no matches for terms shorter than three characters.
+const (
+	Unicode61 = "unicode61"
+	Porter    = "porter"
+	Trigram   = "trigram"
+)
+
+// Tokenizers lists the supported tokenizers.
+var Tokenizers = []string{Unicode61, Porter, Trigram}
+
+// tokenizeOptions maps a tokenizer name to its FTS5 tokenize option.
+var tokenizeOptions = map[string]string{
+	Unicode61: "unicode61",
+	Porter:    "porter unicode61",
+	Trigram:   "trigram",
+}
+
+// Querier is implemented by *sql.DB and *sql.Tx.
+type Querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Tokenizer reports the tokenizer the archival full-text index uses.
+func Tokenizer(q Querier) (string, error) {
+	var def string
+	if err := q.QueryRow(`SELECT sql FROM sqlite_master WHERE name = 'archival_fts'`).Scan(&def); err != nil {
+		return "", fmt.Errorf("read archival_fts definition: %w", err)
+	}
+	switch def = strings.ToLower(def); {
+	case strings.Contains(def, "trigram"):
+		return Trigram, nil
+	case strings.Contains(def, "porter"):
+		return Porter, nil
+	default:
+		return Unicode61, nil
+	}
+}
+
+// SetTokenizer recreates the archival full-text index with the named
+// tokenizer and re-indexes every entry. The sync triggers refer to the index
+// by name, so they keep working unchanged.
+func SetTokenizer(db *sql.DB, name string) error {
+	option, ok := tokenizeOptions[name]
+	if !ok {
+		return fmt.Errorf("unknown tokenizer %q — use %s", name, strings.Join(Tokenizers, ", "))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = execAll(
+		`DROP TABLE archival_fts`,
+		`CREATE VIRTUAL TABLE archival_fts USING fts5(
+			content,
+			tags,
+			content='archival',
+			content_rowid='id',
+			tokenize='`+option+`'
+		)`,
+		`INSERT INTO archival_fts(archival_fts) VALUES ('rebuild')`,
+	)(tx)
+	if err != nil {
+		return fmt.Errorf("rebuild archival_fts: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSetTokenizer_RebuildsIndex(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	database.Exec(`INSERT INTO archival (content, tags) VALUES ('Stu prefers outside contracts', 'work')`)
+	count := func(query string) int {
+		var n int
+		if err := database.QueryRow(`SELECT COUNT(*) FROM archival_fts WHERE archival_fts MATCH ?`, query).Scan(&n); err != nil {
+			t.Fatalf("match %q: %v", query, err)
+		}
+		return n
+	}
+
+	if name, _ := Tokenizer(database); name != Unicode61 {
+		t.Errorf("expected the default tokenizer, got %q", name)
+	}
+	if count("contract") != 0 {
+		t.Error("expected no stemming by default")
+	}
+
+	if err := SetTokenizer(database, Porter); err != nil {
+		t.Fatalf("set porter: %v", err)
+	}
+	if name, _ := Tokenizer(database); name != Porter {
+		t.Errorf("expected porter, got %q", name)
+	}
+	if count("contract") != 1 {
+		t.Error("expected porter to match the stem")
+	}
+
+	if err := SetTokenizer(database, Trigram); err != nil {
+		t.Fatalf("set trigram: %v", err)
+	}
+	if count(`"refers"`) != 1 {
+		t.Error("expected trigram to match inside a word")
+	}
+
+	// New entries are indexed through the existing triggers.
+	database.Exec(`INSERT INTO archival (content, tags) VALUES ('会议在东京举行', '')`)
+	if count(`"东京举"`) != 1 {
+		t.Error("expected trigram to match CJK text added after the rebuild")
+	}
+
+	if err := SetTokenizer(database, "bogus"); err == nil {
+		t.Error("expected an error for an unknown tokenizer")
+	}
+}
@@ -2,9 +2,12 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -12,6 +15,20 @@ import (
 // Open opens or creates the botmem database at the default location (~/.botmem/botmem.db).
 // If dbPath is empty, the default path is used.
 func Open(dbPath string) (*sql.DB, error) {
+	db, err := OpenNoMigrate(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return db, nil
+}
+
+// OpenNoMigrate opens the database like Open but leaves its schema as it
+// is, so pending migrations can be reported without applying them.
+func OpenNoMigrate(dbPath string) (*sql.DB, error) {
 	if dbPath == "" {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -28,17 +45,33 @@ func Open(dbPath string) (*sql.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
+	return db, nil
+}
 
-	if err := migrate(db); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("migrate: %w", err)
-	}
+// ErrSchemaTooNew is returned by Open when the database was migrated by a
+// newer botmem than this binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this botmem supports — upgrade botmem")
 
-	return db, nil
+// Migration is one numbered schema change. Migrations are applied in order,
+// each in its own transaction, and recorded in the schema_version table.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
 }
 
-func migrate(db *sql.DB) error {
-	migrations := []string{
+// migrations is the ordered schema history. Never edit or reorder an entry
+// once released — append a new one instead. Version 1 uses IF NOT EXISTS so
+// databases created before versioning adopt it cleanly.
+var migrations = []Migration{
+	{1, "initial schema", execAll(
 		`CREATE TABLE IF NOT EXISTS memory_blocks (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			label TEXT NOT NULL UNIQUE,
@@ -104,12 +137,352 @@ func migrate(db *sql.DB) error {
 			source_ids TEXT NOT NULL DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
+	)},
+	{2, "ann index", execAll(
+		`CREATE TABLE IF NOT EXISTS ann_meta (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			dim INTEGER NOT NULL,
+			built_count INTEGER NOT NULL,
+			built_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS ann_centroids (
+			id INTEGER PRIMARY KEY,
+			vector BLOB NOT NULL
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS ann_assignments (
+			archival_id INTEGER PRIMARY KEY REFERENCES archival(id) ON DELETE CASCADE,
+			centroid_id INTEGER NOT NULL REFERENCES ann_centroids(id) ON DELETE CASCADE
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_ann_assignments_centroid ON ann_assignments(centroid_id)`,
+	)},
+	{3, "ingest provenance", execAll(
+		`CREATE TABLE ingest_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			provider TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL DEFAULT '',
+			input_hash TEXT NOT NULL,
+			raw_output TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`ALTER TABLE archival ADD COLUMN run_id INTEGER REFERENCES ingest_runs(id)`,
+		`ALTER TABLE relations ADD COLUMN run_id INTEGER REFERENCES ingest_runs(id)`,
+		`ALTER TABLE conversation_summaries ADD COLUMN run_id INTEGER REFERENCES ingest_runs(id)`,
+		`CREATE INDEX idx_archival_run ON archival(run_id)`,
+		`CREATE INDEX idx_relations_run ON relations(run_id)`,
+		`CREATE INDEX idx_summaries_run ON conversation_summaries(run_id)`,
+
+		// Block writes made by a run; previous_content is NULL when the run
+		// created the block.
+		`CREATE TABLE ingest_block_changes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id INTEGER NOT NULL REFERENCES ingest_runs(id) ON DELETE CASCADE,
+			label TEXT NOT NULL,
+			previous_content TEXT,
+			new_content TEXT NOT NULL
+		)`,
+		`CREATE INDEX idx_block_changes_run ON ingest_block_changes(run_id)`,
+	)},
+	{4, "ingest undo", execAll(
+		`ALTER TABLE ingest_runs ADD COLUMN undone_at DATETIME`,
+	)},
+	{5, "ingest fact changes", execAll(
+		// Updates and deletes of existing archival entries made by a run,
+		// with the entry as it was so the change can be undone.
+		`CREATE TABLE ingest_fact_changes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id INTEGER NOT NULL REFERENCES ingest_runs(id) ON DELETE CASCADE,
+			archival_id INTEGER NOT NULL,
+			op TEXT NOT NULL,
+			previous_content TEXT NOT NULL,
+			previous_tags TEXT NOT NULL DEFAULT '',
+			previous_embedding BLOB,
+			previous_run_id INTEGER,
+			previous_created_at DATETIME,
+			new_content TEXT
+		)`,
+		`CREATE INDEX idx_fact_changes_run ON ingest_fact_changes(run_id)`,
+	)},
+	{6, "embedding cache", execAll(
+		`CREATE TABLE embedding_cache (
+			model TEXT NOT NULL,
+			text_hash TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (model, text_hash)
+		)`,
+	)},
+	{7, "embedding model", execAll(
+		// Which model produced each embedding, so vectors from a different
+		// model are never compared. Existing rows keep a NULL (unknown) model
+		// until they are re-embedded.
+		`ALTER TABLE archival ADD COLUMN embedding_model TEXT`,
+		`ALTER TABLE archival ADD COLUMN embedding_dim INTEGER`,
+		`UPDATE archival SET embedding_dim = length(embedding) / 4 WHERE embedding IS NOT NULL`,
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_embedding_model TEXT`,
+	)},
+	{8, "fact change embedding dim", execAll(
+		// Quantized embeddings can't be decoded without their dimension.
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_embedding_dim INTEGER`,
+	)},
+	{9, "normalized tags", migrateTags},
+	{10, "fts vocabulary", execAll(
+		// The indexed terms, for suggesting near matches when a search
+		// finds nothing.
+		`CREATE VIRTUAL TABLE archival_vocab USING fts5vocab(archival_fts, row)`,
+	)},
+	{11, "archival sources", execAll(
+		// Entries loaded from a file record its path and where in the file
+		// each chunk starts, so the file can be re-synced.
+		`ALTER TABLE archival ADD COLUMN source TEXT`,
+		`ALTER TABLE archival ADD COLUMN source_offset INTEGER`,
+		`CREATE INDEX idx_archival_source ON archival(source) WHERE source IS NOT NULL`,
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_source TEXT`,
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_source_offset INTEGER`,
+	)},
+	{12, "archival metadata", execAll(
+		// Arbitrary JSON object per entry (author, URL, conversation id...),
+		// queried with json_extract.
+		`ALTER TABLE archival ADD COLUMN metadata TEXT`,
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_metadata TEXT`,
+	)},
+	{13, "importance and access", execAll(
+		// importance runs from 0 (trivial) to 1 (never forget). Archival
+		// searches, graph queries and context export bump the access
+		// columns; 'botmem forget' sets archived_at on memories it archives,
+		// which hides them from searches, listings and context.
+		`ALTER TABLE archival ADD COLUMN importance REAL NOT NULL DEFAULT 0.5`,
+		`ALTER TABLE archival ADD COLUMN access_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE archival ADD COLUMN last_accessed_at DATETIME`,
+		`ALTER TABLE archival ADD COLUMN archived_at DATETIME`,
+		`ALTER TABLE relations ADD COLUMN importance REAL NOT NULL DEFAULT 0.5`,
+		`ALTER TABLE relations ADD COLUMN access_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE relations ADD COLUMN last_accessed_at DATETIME`,
+		`ALTER TABLE relations ADD COLUMN archived_at DATETIME`,
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_importance REAL`,
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_access_count INTEGER`,
+		`ALTER TABLE ingest_fact_changes ADD COLUMN previous_last_accessed_at DATETIME`,
+		// Searches now write to archival rows; only re-index the text columns.
+		`DROP TRIGGER archival_au`,
+		`CREATE TRIGGER archival_au AFTER UPDATE OF content, tags ON archival BEGIN
+			INSERT INTO archival_fts(archival_fts, rowid, content, tags) VALUES('delete', old.id, old.content, old.tags);
+			INSERT INTO archival_fts(rowid, content, tags) VALUES (new.id, new.content, new.tags);
+		END`,
+	)},
+	{14, "run fact content", execAll(
+		// The content an ingest run created each fact with, so undoing the
+		// run leaves facts edited since alone. NULL for older facts.
+		`ALTER TABLE archival ADD COLUMN run_content TEXT`,
+	)},
+}
+
+// execAll returns a migration step that runs each statement in order.
+func execAll(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("%w\nSQL: %s", err, stmt)
+			}
+		}
+		return nil
+	}
+}
+
+// migrateTags moves archival tags into the tags and archival_tags tables.
+// archival.tags is kept, in normalized form, as the display and full-text
+// copy of each entry's tags; the tables are what filters match against.
+func migrateTags(tx *sql.Tx) error {
+	err := execAll(
+		`CREATE TABLE tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE
+		)`,
+		// position keeps each entry's tags in the order they were given.
+		`CREATE TABLE archival_tags (
+			archival_id INTEGER NOT NULL REFERENCES archival(id) ON DELETE CASCADE,
+			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			position INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (archival_id, tag_id)
+		)`,
+		`CREATE INDEX idx_archival_tags_tag ON archival_tags(tag_id)`,
+	)(tx)
+	if err != nil {
+		return err
+	}
+
+	type tagged struct {
+		id   int64
+		tags string
+	}
+	var entries []tagged
+	rows, err := tx.Query(`SELECT id, tags FROM archival WHERE tags != ''`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var e tagged
+		if err := rows.Scan(&e.id, &e.tags); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		var names []string
+		seen := map[string]bool{}
+		for _, t := range strings.Split(e.tags, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" || seen[strings.ToLower(t)] {
+				continue
+			}
+			seen[strings.ToLower(t)] = true
+			names = append(names, t)
+		}
+		for i, name := range names {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, name); err != nil {
+				return err
+			}
+			// Tags differing only in case share the first spelling seen.
+			var tagID int64
+			if err := tx.QueryRow(`SELECT id, name FROM tags WHERE name = ?`, name).Scan(&tagID, &names[i]); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(
+				`INSERT INTO archival_tags (archival_id, tag_id, position) VALUES (?, ?, ?)`,
+				e.id, tagID, i,
+			); err != nil {
+				return err
+			}
+		}
+		if joined := strings.Join(names, ","); joined != e.tags {
+			if _, err := tx.Exec(`UPDATE archival SET tags = ? WHERE id = ?`, joined, e.id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LatestVersion is the schema version this binary migrates databases to.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// CurrentVersion returns the highest migration applied to db.
+func CurrentVersion(db *sql.DB) (int, error) {
+	var v int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&v)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return v, nil
+}
+
+// Status lists every migration known to this binary and when it was applied.
+// It doesn't migrate db, so migrations not yet applied are listed without an
+// AppliedAt.
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	applied := map[int]time.Time{}
+	var versioned int
+	if err := db.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`,
+	).Scan(&versioned); err != nil {
+		return nil, fmt.Errorf("read schema_version: %w", err)
+	}
+	if versioned == 0 {
+		return statuses(applied), nil
+	}
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_version: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return statuses(applied), nil
+}
+
+// statuses pairs each known migration with when it was applied, if it was.
+func statuses(applied map[int]time.Time) []MigrationStatus {
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses
+}
+
+// Migrate applies every pending migration to db. Open calls it; use it
+// directly on a database from OpenNoMigrate.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_version: %w", err)
+	}
+
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > LatestVersion() {
+		return fmt.Errorf("%w (database v%d, botmem v%d)", ErrSchemaTooNew, current, LatestVersion())
 	}
 
 	for _, m := range migrations {
-		if _, err := db.Exec(m); err != nil {
-			return fmt.Errorf("exec migration: %w\nSQL: %s", err, m)
+		if m.Version <= current {
+			continue
+		}
+		if err := apply(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
 		}
 	}
 	return nil
 }
+
+// apply runs a single migration and records it, all in one transaction.
+func apply(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Another process may have applied it since we read the version.
+	var done int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM schema_version WHERE version = ?`, m.Version).Scan(&done); err != nil {
+		return err
+	}
+	if done > 0 {
+		return nil
+	}
+
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+		return fmt.Errorf("record version: %w", err)
+	}
+	return tx.Commit()
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -102,3 +104,185 @@ func TestForeignKeysEnabled(t *testing.T) {
 		t.Errorf("expected foreign_keys=1, got %d", fk)
 	}
 }
+
+func TestMigrations_RecordsSchemaVersion(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer database.Close()
+
+	v, err := CurrentVersion(database)
+	if err != nil {
+		t.Fatalf("CurrentVersion: %v", err)
+	}
+	if v != LatestVersion() {
+		t.Errorf("expected version %d, got %d", LatestVersion(), v)
+	}
+
+	statuses, err := Status(database)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != len(migrations) {
+		t.Fatalf("expected %d statuses, got %d", len(migrations), len(statuses))
+	}
+	for _, st := range statuses {
+		if st.AppliedAt == nil {
+			t.Errorf("migration %d (%s) not applied", st.Version, st.Name)
+		}
+	}
+}
+
+func TestMigrations_OrderedAndUnique(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %q has version %d, expected %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func TestMigrations_UpgradesUnversionedDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Simulate a database created before schema versioning existed.
+	raw, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Exec(`CREATE TABLE memory_blocks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		label TEXT NOT NULL UNIQUE,
+		block_type TEXT NOT NULL DEFAULT 'core',
+		content TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Exec(`INSERT INTO memory_blocks (label, content) VALUES ('human', 'Stuart')`); err != nil {
+		t.Fatal(err)
+	}
+	raw.Close()
+
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer database.Close()
+
+	var content string
+	if err := database.QueryRow(`SELECT content FROM memory_blocks WHERE label = 'human'`).Scan(&content); err != nil {
+		t.Fatalf("existing data lost: %v", err)
+	}
+	if v, _ := CurrentVersion(database); v != LatestVersion() {
+		t.Errorf("expected version %d after upgrade, got %d", LatestVersion(), v)
+	}
+}
+
+func TestStatus_ReportsPendingWithoutMigrating(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Bring a database up to an older schema by applying only the first
+	// few migrations.
+	database, err := OpenNoMigrate(dbPath)
+	if err != nil {
+		t.Fatalf("OpenNoMigrate: %v", err)
+	}
+	defer database.Close()
+	if st, err := Status(database); err != nil || st[0].AppliedAt != nil {
+		t.Fatalf("expected an unversioned database to have nothing applied, got %+v (%v)", st, err)
+	}
+	if _, err := database.Exec(`CREATE TABLE schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	const older = 3
+	for _, m := range migrations[:older] {
+		if err := apply(database, m); err != nil {
+			t.Fatalf("apply %d: %v", m.Version, err)
+		}
+	}
+
+	statuses, err := Status(database)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, st := range statuses {
+		if applied := st.AppliedAt != nil; applied != (st.Version <= older) {
+			t.Errorf("migration %d (%s): expected applied=%v", st.Version, st.Name, !applied)
+		}
+	}
+	if v, _ := CurrentVersion(database); v != older {
+		t.Errorf("expected Status to leave the schema at version %d, got %d", older, v)
+	}
+
+	if err := Migrate(database); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if v, _ := CurrentVersion(database); v != LatestVersion() {
+		t.Errorf("expected version %d after Migrate, got %d", LatestVersion(), v)
+	}
+}
+
+func TestOpen_RefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := database.Exec(`INSERT INTO schema_version (version, name) VALUES (?, 'from the future')`, LatestVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	database.Close()
+
+	_, err = Open(dbPath)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestMigrateTags_BackfillsExistingTags(t *testing.T) {
+	raw, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(`CREATE TABLE schema_version (
+		version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:8] {
+		if err := apply(raw, m); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+	for _, tags := range []string{"work, homework", "Work,,network,work", ""} {
+		if _, err := raw.Exec(`INSERT INTO archival (content, tags) VALUES ('fact', ?)`, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Migrate(raw); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var tags string
+	raw.QueryRow(`SELECT tags FROM archival WHERE id = 2`).Scan(&tags)
+	if tags != "work,network" {
+		t.Errorf("expected normalized tags %q, got %q", "work,network", tags)
+	}
+	var n int
+	raw.QueryRow(`SELECT COUNT(*) FROM archival_tags x JOIN tags t ON t.id = x.tag_id WHERE t.name = 'work'`).Scan(&n)
+	if n != 2 {
+		t.Errorf("expected 2 entries tagged work, got %d", n)
+	}
+	raw.QueryRow(`SELECT COUNT(*) FROM tags`).Scan(&n)
+	if n != 3 {
+		t.Errorf("expected 3 distinct tags, got %d", n)
+	}
+}
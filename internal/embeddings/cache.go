@@ -0,0 +1,89 @@
+package embeddings
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Cache stores embeddings keyed by model ID and a hash of the embedded text.
+type Cache interface {
+	// Get returns the cached vectors for whichever of the hashes it has.
+	Get(modelID string, hashes []string) (map[string][]float32, error)
+	// Put stores vectors by hash.
+	Put(modelID string, vecs map[string][]float32) error
+}
+
+// TextHash is the cache key for text: its hex-encoded SHA-256.
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// CachedProvider serves embeddings from a Cache and only asks the wrapped
+// provider for texts it hasn't seen with the same model. The cache is best
+// effort: if it can't be read or written the provider is used directly.
+type CachedProvider struct {
+	Provider
+	Cache Cache
+}
+
+// NewCachedProvider wraps p with cache. The built-in local provider is
+// returned as-is, since computing its vectors is cheaper than a lookup.
+func NewCachedProvider(p Provider, cache Cache) Provider {
+	if _, ok := p.(*LocalProvider); ok || p == nil || cache == nil {
+		return p
+	}
+	return &CachedProvider{Provider: p, Cache: cache}
+}
+
+func (c *CachedProvider) Embed(text string) ([]float32, error) {
+	return embedOne(c, text)
+}
+
+// BatchEmbed looks every text up in the cache, embeds the misses in a single
+// batch (each distinct text once) and caches the new vectors.
+func (c *CachedProvider) BatchEmbed(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	model := c.Provider.ModelID()
+	hashes := make([]string, len(texts))
+	for i, t := range texts {
+		hashes[i] = TextHash(t)
+	}
+
+	cached, err := c.Cache.Get(model, hashes)
+	if err != nil || cached == nil {
+		cached = map[string][]float32{}
+	}
+
+	var missTexts, missHashes []string
+	seen := map[string]bool{}
+	for i, h := range hashes {
+		if _, ok := cached[h]; ok || seen[h] {
+			continue
+		}
+		seen[h] = true
+		missTexts = append(missTexts, texts[i])
+		missHashes = append(missHashes, h)
+	}
+
+	if len(missTexts) > 0 {
+		vecs, err := c.Provider.BatchEmbed(missTexts)
+		if err != nil {
+			return nil, err
+		}
+		fresh := make(map[string][]float32, len(vecs))
+		for i, v := range vecs {
+			fresh[missHashes[i]] = v
+			cached[missHashes[i]] = v
+		}
+		c.Cache.Put(model, fresh)
+	}
+
+	out := make([][]float32, len(texts))
+	for i, h := range hashes {
+		out[i] = cached[h]
+	}
+	return out, nil
+}
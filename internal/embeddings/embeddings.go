@@ -7,11 +7,36 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"os"
 )
 
-// Provider generates embeddings from text.
+// Provider generates embeddings from text. BatchEmbed returns one vector per
+// input, in order. ModelID identifies the model (and any setting that
+// changes its vectors) so stored and cached vectors can be matched to it.
 type Provider interface {
 	Embed(text string) ([]float32, error)
+	BatchEmbed(texts []string) ([][]float32, error)
+	ModelID() string
+}
+
+// New builds the embeddings provider called name: "ollama" (the default
+// when name is empty), "openai" for any OpenAI-compatible server, or
+// "local" for the built-in offline provider. An empty OpenAI API key is read
+// from OPENAI_API_KEY.
+func New(name, baseURL, model, apiKey string, dimensions int) (Provider, error) {
+	switch name {
+	case "", "ollama":
+		return NewOllamaProvider(baseURL, model), nil
+	case "local":
+		return NewLocalProvider(dimensions), nil
+	case "openai":
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		return NewOpenAIProvider(baseURL, model, apiKey, dimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider %q — use ollama, openai, or local", name)
+	}
 }
 
 // OllamaProvider uses a local Ollama instance for embeddings.
@@ -31,16 +56,28 @@ func NewOllamaProvider(baseURL, model string) *OllamaProvider {
 }
 
 type ollamaEmbedRequest struct {
-	Model string `json:"model"`
-	Input string `json:"input"`
+	Model string   `json:"model"`
+	Input []string `json:"input"`
 }
 
 type ollamaEmbedResponse struct {
 	Embeddings [][]float32 `json:"embeddings"`
 }
 
+func (p *OllamaProvider) ModelID() string {
+	return "ollama/" + p.Model
+}
+
 func (p *OllamaProvider) Embed(text string) ([]float32, error) {
-	body, err := json.Marshal(ollamaEmbedRequest{Model: p.Model, Input: text})
+	return embedOne(p, text)
+}
+
+// BatchEmbed embeds every text in one /api/embed request.
+func (p *OllamaProvider) BatchEmbed(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(ollamaEmbedRequest{Model: p.Model, Input: texts})
 	if err != nil {
 		return nil, err
 	}
@@ -59,10 +96,19 @@ func (p *OllamaProvider) Embed(text string) ([]float32, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("decode embed response: %w", err)
 	}
-	if len(result.Embeddings) == 0 {
-		return nil, fmt.Errorf("no embeddings returned")
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+	return result.Embeddings, nil
+}
+
+// embedOne embeds a single text through the provider's batch call.
+func embedOne(p Provider, text string) ([]float32, error) {
+	vecs, err := p.BatchEmbed([]string{text})
+	if err != nil {
+		return nil, err
 	}
-	return result.Embeddings[0], nil
+	return vecs[0], nil
 }
 
 // SerializeEmbedding converts float32 slice to bytes for SQLite BLOB storage.
@@ -99,3 +145,108 @@ func CosineSimilarity(a, b []float32) float32 {
 	}
 	return dot / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
 }
+
+// Normalize returns a unit-length copy of v. A zero vector is returned as-is.
+func Normalize(v []float32) []float32 {
+	var norm float64
+	for _, f := range v {
+		norm += float64(f) * float64(f)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		copy(out, v)
+		return out
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i, f := range v {
+		out[i] = f * inv
+	}
+	return out
+}
+
+// Nearest returns the index of the centroid with the highest dot product
+// with v, or -1 if there are no centroids of matching length. For
+// unit-length centroids this is the most cosine-similar one.
+func Nearest(centroids [][]float32, v []float32) int {
+	best, bestDot := -1, float32(math.Inf(-1))
+	for i, c := range centroids {
+		if len(c) != len(v) {
+			continue
+		}
+		var dot float32
+		for j := range c {
+			dot += c[j] * v[j]
+		}
+		if dot > bestDot {
+			best, bestDot = i, dot
+		}
+	}
+	return best
+}
+
+// KMeans clusters vectors into at most k groups by cosine similarity
+// (spherical k-means) and returns unit-length centroids. All vectors must
+// have the same length. Initial centroids are spread evenly through the
+// input, so the result is deterministic.
+func KMeans(vectors [][]float32, k, iterations int) [][]float32 {
+	if len(vectors) == 0 || k <= 0 {
+		return nil
+	}
+	if k > len(vectors) {
+		k = len(vectors)
+	}
+	points := make([][]float32, len(vectors))
+	for i, v := range vectors {
+		points[i] = Normalize(v)
+	}
+
+	centroids := make([][]float32, k)
+	for i := range centroids {
+		centroids[i] = append([]float32(nil), points[i*len(points)/k]...)
+	}
+
+	dim := len(points[0])
+	assign := make([]int, len(points))
+	for iter := 0; iter < iterations; iter++ {
+		changed := iter == 0
+		for i, p := range points {
+			if c := Nearest(centroids, p); c != assign[i] {
+				assign[i] = c
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][]float32, k)
+		for i := range sums {
+			sums[i] = make([]float32, dim)
+		}
+		for i, p := range points {
+			if assign[i] < 0 {
+				continue
+			}
+			s := sums[assign[i]]
+			for j := range p {
+				s[j] += p[j]
+			}
+		}
+		for i, s := range sums {
+			// An empty cluster keeps its previous centroid.
+			if anyNonZero(s) {
+				centroids[i] = Normalize(s)
+			}
+		}
+	}
+	return centroids
+}
+
+func anyNonZero(v []float32) bool {
+	for _, f := range v {
+		if f != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,10 @@
 package embeddings
 
 import (
+	"encoding/json"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -89,3 +92,269 @@ func TestCosineSimilarity_Similar(t *testing.T) {
 		t.Errorf("expected high similarity for similar vectors, got %f", sim)
 	}
 }
+
+func TestNormalize(t *testing.T) {
+	n := Normalize([]float32{3, 4})
+	if math.Abs(float64(n[0])-0.6) > 0.0001 || math.Abs(float64(n[1])-0.8) > 0.0001 {
+		t.Errorf("expected [0.6 0.8], got %v", n)
+	}
+	if z := Normalize([]float32{0, 0}); z[0] != 0 || z[1] != 0 {
+		t.Errorf("expected zero vector unchanged, got %v", z)
+	}
+}
+
+func TestNearest(t *testing.T) {
+	centroids := [][]float32{{1, 0}, {0, 1}}
+	if got := Nearest(centroids, []float32{0.2, 0.9}); got != 1 {
+		t.Errorf("expected centroid 1, got %d", got)
+	}
+	if got := Nearest(centroids, []float32{1, 0, 0}); got != -1 {
+		t.Errorf("expected -1 for mismatched length, got %d", got)
+	}
+}
+
+func TestKMeans_SeparatesClusters(t *testing.T) {
+	var vectors [][]float32
+	for i := 0; i < 10; i++ {
+		d := float32(i) * 0.01
+		vectors = append(vectors, []float32{1, d, 0}, []float32{0, d, 1})
+	}
+
+	centroids := KMeans(vectors, 2, 10)
+	if len(centroids) != 2 {
+		t.Fatalf("expected 2 centroids, got %d", len(centroids))
+	}
+	a := Nearest(centroids, []float32{1, 0, 0})
+	b := Nearest(centroids, []float32{0, 0, 1})
+	if a == b {
+		t.Error("expected the two clusters to map to different centroids")
+	}
+}
+
+func TestKMeans_MoreClustersThanPoints(t *testing.T) {
+	centroids := KMeans([][]float32{{1, 0}, {0, 1}}, 5, 5)
+	if len(centroids) != 2 {
+		t.Errorf("expected k capped at 2, got %d", len(centroids))
+	}
+}
+
+func TestOpenAIProvider_Embed(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotReq openaiEmbedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotReq)
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[0.5,-1,2]}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider(srv.URL+"/v1", "bge-small", "sk-emb", 256)
+	vec, err := p.Embed("hello")
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if len(vec) != 3 || vec[1] != -1 {
+		t.Errorf("unexpected vector %v", vec)
+	}
+	if gotPath != "/v1/embeddings" || gotAuth != "Bearer sk-emb" {
+		t.Errorf("unexpected request %s auth=%q", gotPath, gotAuth)
+	}
+	if gotReq.Model != "bge-small" || len(gotReq.Input) != 1 || gotReq.Input[0] != "hello" || gotReq.Dimensions != 256 {
+		t.Errorf("unexpected body %+v", gotReq)
+	}
+
+	if _, err := NewOpenAIProvider(srv.URL, "", "", 0).Embed("x"); err != nil || gotPath != "/v1/embeddings" {
+		t.Errorf("expected root base URL to get /v1 appended, got %s (%v)", gotPath, err)
+	}
+}
+
+func TestOpenAIProvider_BatchEmbedOrdersByIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[2]},{"index":0,"embedding":[1]}]}`))
+	}))
+	defer srv.Close()
+
+	vecs, err := NewOpenAIProvider(srv.URL, "", "", 0).BatchEmbed([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("batch embed: %v", err)
+	}
+	if vecs[0][0] != 1 || vecs[1][0] != 2 {
+		t.Errorf("expected vectors in input order, got %v", vecs)
+	}
+}
+
+func TestOllamaProvider_BatchEmbed(t *testing.T) {
+	var gotPath string
+	var gotReq map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotReq)
+		w.Write([]byte(`{"embeddings":[[1,0],[0,1]]}`))
+	}))
+	defer srv.Close()
+
+	vecs, err := NewOllamaProvider(srv.URL, "nomic-embed-text").BatchEmbed([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("batch embed: %v", err)
+	}
+	if gotPath != "/api/embed" || len(gotReq["input"].([]any)) != 2 {
+		t.Errorf("unexpected request %s %v", gotPath, gotReq)
+	}
+	if len(vecs) != 2 || vecs[1][1] != 1 {
+		t.Errorf("unexpected vectors %v", vecs)
+	}
+}
+
+// countingProvider records every text it is asked to embed.
+type countingProvider struct {
+	LocalProvider
+	embedded []string
+}
+
+func (p *countingProvider) BatchEmbed(texts []string) ([][]float32, error) {
+	p.embedded = append(p.embedded, texts...)
+	return p.LocalProvider.BatchEmbed(texts)
+}
+
+type mapCache map[string][]float32
+
+func (c mapCache) Get(modelID string, hashes []string) (map[string][]float32, error) {
+	found := map[string][]float32{}
+	for _, h := range hashes {
+		if v, ok := c[modelID+h]; ok {
+			found[h] = v
+		}
+	}
+	return found, nil
+}
+
+func (c mapCache) Put(modelID string, vecs map[string][]float32) error {
+	for h, v := range vecs {
+		c[modelID+h] = v
+	}
+	return nil
+}
+
+func TestCachedProvider_EmbedsEachTextOnce(t *testing.T) {
+	inner := &countingProvider{LocalProvider: LocalProvider{Dimensions: 8}}
+	p := NewCachedProvider(inner, mapCache{})
+
+	vecs, err := p.BatchEmbed([]string{"a", "b", "a"})
+	if err != nil {
+		t.Fatalf("batch embed: %v", err)
+	}
+	if len(vecs) != 3 || CosineSimilarity(vecs[0], vecs[2]) < 0.999 {
+		t.Fatalf("expected duplicate texts to share a vector, got %v", vecs)
+	}
+	if len(inner.embedded) != 2 {
+		t.Errorf("expected 2 texts embedded, got %v", inner.embedded)
+	}
+
+	if _, err := p.Embed("b"); err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if len(inner.embedded) != 2 {
+		t.Errorf("expected cache hit for repeated text, got %v", inner.embedded)
+	}
+
+	if _, ok := NewCachedProvider(NewLocalProvider(8), mapCache{}).(*LocalProvider); !ok {
+		t.Error("expected the local provider to bypass the cache")
+	}
+}
+
+func TestNew_SelectsProvider(t *testing.T) {
+	if p, err := New("", "", "", "", 0); err != nil || p.(*OllamaProvider).Model != "nomic-embed-text" {
+		t.Errorf("expected ollama default, got %+v, %v", p, err)
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-env")
+	if p, err := New("openai", "http://tei:3000", "", "", 0); err != nil || p.(*OpenAIProvider).APIKey != "sk-env" {
+		t.Errorf("expected openai with env key, got %+v, %v", p, err)
+	}
+	if _, err := New("cohere", "", "", "", 0); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestLocalProvider_Deterministic(t *testing.T) {
+	p := NewLocalProvider(0)
+	a, _ := p.Embed("Stu prefers Outside IR35 contracts")
+	b, _ := p.Embed("Stu prefers Outside IR35 contracts")
+	if len(a) != DefaultLocalDimensions {
+		t.Fatalf("expected %d dims, got %d", DefaultLocalDimensions, len(a))
+	}
+	if sim := CosineSimilarity(a, b); math.Abs(float64(sim)-1) > 1e-5 {
+		t.Errorf("expected identical text to give identical vectors, got %f", sim)
+	}
+}
+
+func TestLocalProvider_RanksRelatedTextHigher(t *testing.T) {
+	p := NewLocalProvider(256)
+	query, _ := p.Embed("Stuart's contract preferences")
+	near, _ := p.Embed("Stuart prefers outside IR35 contracts")
+	far, _ := p.Embed("The weather in Glasgow was rainy all week")
+
+	if CosineSimilarity(query, near) <= CosineSimilarity(query, far) {
+		t.Errorf("expected related text to score higher: near=%f far=%f",
+			CosineSimilarity(query, near), CosineSimilarity(query, far))
+	}
+}
+
+func TestLocalProvider_EmptyText(t *testing.T) {
+	vec, err := NewLocalProvider(16).Embed("  ?! ")
+	if err != nil || len(vec) != 16 {
+		t.Fatalf("expected zero vector of 16 dims, got %v, %v", vec, err)
+	}
+	for _, f := range vec {
+		if f != 0 {
+			t.Fatalf("expected zero vector, got %v", vec)
+		}
+	}
+}
+
+func TestQuantization_RoundTrip(t *testing.T) {
+	v := []float32{0.5, -0.25, 0.125, 0, -1, 0.75, 0.3, -0.6, 0.9}
+	for _, q := range Quantizations {
+		b := Encode(v, q)
+		if len(b) != EncodedSize(len(v), q) {
+			t.Errorf("%s: expected %d bytes, got %d", q, EncodedSize(len(v), q), len(b))
+		}
+		if got, ok := FormatOf(len(b), len(v)); !ok || got != q {
+			t.Errorf("%s: detected as %q", q, got)
+		}
+		decoded := Decode(b, len(v))
+		if len(decoded) != len(v) {
+			t.Fatalf("%s: expected %d dims, got %d", q, len(v), len(decoded))
+		}
+		min := float32(0.999)
+		if q == Binary {
+			min = 0.7
+		}
+		if sim := CosineSimilarity(v, decoded); sim < min {
+			t.Errorf("%s: decoded vector similarity %f below %f", q, sim, min)
+		}
+	}
+}
+
+func TestSimilarity_MatchesDecodedCosine(t *testing.T) {
+	stored := []float32{0.2, -0.4, 0.6, 0.1, -0.3}
+	query := []float32{0.1, -0.5, 0.5, 0, -0.2}
+	for _, q := range Quantizations {
+		b := Encode(stored, q)
+		want := CosineSimilarity(query, Decode(b, len(stored)))
+		if got := Similarity(query, b, len(stored)); math.Abs(float64(got-want)) > 1e-5 {
+			t.Errorf("%s: expected %f, got %f", q, want, got)
+		}
+	}
+	if Similarity(query[:4], Encode(stored, Int8), len(stored)) != 0 {
+		t.Error("expected 0 for mismatched dimensions")
+	}
+}
+
+func TestParseQuantization(t *testing.T) {
+	if q, err := ParseQuantization(""); err != nil || q != Float32 {
+		t.Errorf("expected float32 default, got %q, %v", q, err)
+	}
+	if _, err := ParseQuantization("int4"); err == nil {
+		t.Error("expected error for unknown quantization")
+	}
+}
@@ -0,0 +1,104 @@
+package embeddings
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// LocalModel names the built-in embedding scheme. Bump it if the features
+// or hashing change, since vectors from different versions don't compare.
+const LocalModel = "botmem-hash-v1"
+
+// DefaultLocalDimensions is the vector size used when none is configured.
+const DefaultLocalDimensions = 384
+
+// Feature weights for the local provider. Words carry most of the signal;
+// bigrams reward shared phrasing and character trigrams let inflections
+// ("prefer", "prefers", "preferred") overlap.
+const (
+	localWordWeight    = 1.0
+	localBigramWeight  = 0.5
+	localTrigramWeight = 0.25
+)
+
+// LocalProvider embeds text in-process with no external service, by hashing
+// word, word-bigram and character-trigram features into a fixed number of
+// dimensions (the "hashing trick"). It captures lexical rather than deep
+// semantic similarity: good for near-duplicate detection and rough recall.
+type LocalProvider struct {
+	Dimensions int
+}
+
+func NewLocalProvider(dimensions int) *LocalProvider {
+	if dimensions <= 0 {
+		dimensions = DefaultLocalDimensions
+	}
+	return &LocalProvider{Dimensions: dimensions}
+}
+
+func (p *LocalProvider) ModelID() string {
+	return fmt.Sprintf("local/%s@%d", LocalModel, p.Dimensions)
+}
+
+func (p *LocalProvider) BatchEmbed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, t := range texts {
+		vecs[i], _ = p.Embed(t)
+	}
+	return vecs, nil
+}
+
+func (p *LocalProvider) Embed(text string) ([]float32, error) {
+	acc := make([]float64, p.Dimensions)
+	add := func(feature string, weight float64) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The top bit picks a sign so colliding features tend to cancel
+		// rather than pile up.
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		acc[sum%uint64(p.Dimensions)] += weight
+	}
+
+	words := localTokens(text)
+	for i, w := range words {
+		add("w:"+w, localWordWeight)
+		if i > 0 {
+			add("b:"+words[i-1]+" "+w, localBigramWeight)
+		}
+		padded := []rune(" " + w + " ")
+		for j := 0; j+3 <= len(padded); j++ {
+			add("c:"+string(padded[j:j+3]), localTrigramWeight)
+		}
+	}
+
+	// Dampen repeated features so one word said many times doesn't dominate.
+	vec := make([]float32, p.Dimensions)
+	for i, v := range acc {
+		if v != 0 {
+			vec[i] = float32(math.Copysign(math.Log1p(math.Abs(v)), v))
+		}
+	}
+	return Normalize(vec), nil
+}
+
+// localTokens lowercases text and splits it into words, dropping a trailing
+// possessive "'s".
+func localTokens(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+	words := fields[:0]
+	for _, f := range fields {
+		f = strings.TrimSuffix(strings.TrimSuffix(f, "'s"), "’s")
+		if f = strings.Trim(f, "'’"); f != "" {
+			words = append(words, f)
+		}
+	}
+	return words
+}
@@ -0,0 +1,119 @@
+package embeddings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider speaks the OpenAI /v1/embeddings protocol, as served by
+// OpenAI itself and by text-embeddings-inference, llama.cpp server and
+// LocalAI.
+type OpenAIProvider struct {
+	BaseURL    string // with or without the trailing /v1
+	Model      string // may be empty for servers that host a single model
+	APIKey     string // optional for local servers
+	Dimensions int    // requested output size; 0 uses the model's native size
+}
+
+func NewOpenAIProvider(baseURL, model, apiKey string, dimensions int) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return &OpenAIProvider{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Model:      model,
+		APIKey:     apiKey,
+		Dimensions: dimensions,
+	}
+}
+
+type openaiEmbedRequest struct {
+	Model      string   `json:"model,omitempty"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openaiEmbedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// endpoint returns the embeddings URL, accepting base URLs given either as
+// the server root or as its /v1 prefix.
+func (p *OpenAIProvider) endpoint() string {
+	if strings.HasSuffix(p.BaseURL, "/v1") {
+		return p.BaseURL + "/embeddings"
+	}
+	return p.BaseURL + "/v1/embeddings"
+}
+
+// ModelID names the model, or the server when it hosts a single unnamed
+// one, plus any requested dimension.
+func (p *OpenAIProvider) ModelID() string {
+	id := "openai/" + p.Model
+	if p.Model == "" {
+		id = "openai/" + p.BaseURL
+	}
+	if p.Dimensions > 0 {
+		id += fmt.Sprintf("@%d", p.Dimensions)
+	}
+	return id
+}
+
+func (p *OpenAIProvider) Embed(text string) ([]float32, error) {
+	return embedOne(p, text)
+}
+
+// BatchEmbed embeds every text in one /v1/embeddings request.
+func (p *OpenAIProvider) BatchEmbed(texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(openaiEmbedRequest{Model: p.Model, Input: texts, Dimensions: p.Dimensions})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", p.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai embed request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai embed: status %d: %s", resp.StatusCode, msg)
+	}
+
+	var result openaiEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode embed response: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Data))
+	}
+	// The spec doesn't promise response order; index does.
+	vecs := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vecs) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vecs[d.Index] = d.Embedding
+	}
+	return vecs, nil
+}
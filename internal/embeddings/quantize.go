@@ -0,0 +1,167 @@
+package embeddings
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Quantization is a storage encoding for embeddings. Float32 keeps vectors
+// exactly; Int8 stores one signed byte per dimension plus a float32 scale
+// (about a quarter of the size, with negligible ranking loss); Binary keeps
+// only the sign of each dimension (1/32 of the size, for very large archives
+// where approximate recall is acceptable).
+type Quantization string
+
+const (
+	Float32 Quantization = "float32"
+	Int8    Quantization = "int8"
+	Binary  Quantization = "binary"
+)
+
+// Quantizations lists the supported encodings.
+var Quantizations = []Quantization{Float32, Int8, Binary}
+
+// ParseQuantization validates a configured encoding name. An empty name
+// means Float32.
+func ParseQuantization(name string) (Quantization, error) {
+	switch q := Quantization(name); q {
+	case "":
+		return Float32, nil
+	case Float32, Int8, Binary:
+		return q, nil
+	default:
+		return "", fmt.Errorf("unknown embedding quantization %q — use float32, int8, or binary", name)
+	}
+}
+
+// EncodedSize is the number of bytes a dim-dimensional vector takes in q.
+// The sizes never coincide for a given dim, so a stored blob's encoding can
+// be recovered from its length and dimension alone.
+func EncodedSize(dim int, q Quantization) int {
+	switch q {
+	case Int8:
+		return 4 + dim
+	case Binary:
+		return (dim + 7) / 8
+	default:
+		return 4 * dim
+	}
+}
+
+// FormatOf reports which encoding produced a blob of n bytes for a
+// dim-dimensional vector.
+func FormatOf(n, dim int) (Quantization, bool) {
+	for _, q := range Quantizations {
+		if dim > 0 && n == EncodedSize(dim, q) {
+			return q, true
+		}
+	}
+	return "", false
+}
+
+// Encode stores v in encoding q.
+func Encode(v []float32, q Quantization) []byte {
+	switch q {
+	case Int8:
+		var max float64
+		for _, f := range v {
+			max = math.Max(max, math.Abs(float64(f)))
+		}
+		scale := float32(max / 127)
+		buf := make([]byte, 4+len(v))
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(scale))
+		if scale > 0 {
+			for i, f := range v {
+				buf[4+i] = byte(int8(math.Round(float64(f / scale))))
+			}
+		}
+		return buf
+	case Binary:
+		buf := make([]byte, (len(v)+7)/8)
+		for i, f := range v {
+			if f > 0 {
+				buf[i/8] |= 1 << (i % 8)
+			}
+		}
+		return buf
+	default:
+		return SerializeEmbedding(v)
+	}
+}
+
+// Decode recovers a dim-dimensional vector from a blob in any encoding. A
+// binary vector decodes to unit length, with ±1/√dim per dimension. A blob
+// that fits no encoding is read as float32, the only format predating stored
+// dimensions.
+func Decode(b []byte, dim int) []float32 {
+	q, ok := FormatOf(len(b), dim)
+	if !ok {
+		return DeserializeEmbedding(b)
+	}
+	switch q {
+	case Int8:
+		scale := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = float32(int8(b[4+i])) * scale
+		}
+		return v
+	case Binary:
+		unit := float32(1 / math.Sqrt(float64(dim)))
+		v := make([]float32, dim)
+		for i := range v {
+			if b[i/8]&(1<<(i%8)) != 0 {
+				v[i] = unit
+			} else {
+				v[i] = -unit
+			}
+		}
+		return v
+	default:
+		return DeserializeEmbedding(b)
+	}
+}
+
+// Similarity is the cosine similarity between query and a stored blob of
+// dimension dim, computed directly on the encoded values. It returns 0 when
+// the dimensions differ or the blob fits no encoding.
+func Similarity(query []float32, b []byte, dim int) float32 {
+	q, ok := FormatOf(len(b), dim)
+	if !ok || len(query) != dim {
+		return 0
+	}
+
+	var dot, normQ, normB float64
+	for _, f := range query {
+		normQ += float64(f) * float64(f)
+	}
+	switch q {
+	case Int8:
+		// The scale is common to every dimension, so it cancels out.
+		for i, f := range query {
+			x := float64(int8(b[4+i]))
+			dot += float64(f) * x
+			normB += x * x
+		}
+	case Binary:
+		for i, f := range query {
+			if b[i/8]&(1<<(i%8)) != 0 {
+				dot += float64(f)
+			} else {
+				dot -= float64(f)
+			}
+		}
+		normB = float64(dim)
+	default:
+		for i, f := range query {
+			x := float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:])))
+			dot += float64(f) * x
+			normB += x * x
+		}
+	}
+	if normQ == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normQ) * math.Sqrt(normB)))
+}
@@ -1,17 +1,16 @@
 package ingest
 
 import (
-	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"os/exec"
+	"math"
 	"strings"
 
 	"github.com/stukennedy/botmem/internal/embeddings"
+	"github.com/stukennedy/botmem/internal/llm"
 	"github.com/stukennedy/botmem/internal/memory"
 )
 
@@ -21,6 +20,7 @@ type ExtractionResult struct {
 	Facts        []Fact        `json:"facts"`
 	Triplets     []Triplet     `json:"triplets"`
 	Summary      string        `json:"summary"`
+	RunID        int64         `json:"run_id,omitempty"` // set once the result is stored
 }
 
 type BlockUpdate struct {
@@ -28,245 +28,326 @@ type BlockUpdate struct {
 	Content string `json:"content"`
 }
 
+// Fact is an operation on archival memory. Op defaults to FactAdd; the
+// other operations refer to an existing entry by ID, which must be one of
+// the related facts shown to the LLM.
 type Fact struct {
-	Content string   `json:"content"`
-	Tags    []string `json:"tags"`
+	Op         string   `json:"op,omitempty"`
+	ID         int64    `json:"id,omitempty"`
+	Content    string   `json:"content"`
+	Tags       []string `json:"tags"`
+	Importance *float64 `json:"importance,omitempty"` // 0-1; nil keeps the default or current importance
 }
 
+// Fact operations.
+const (
+	FactAdd    = "add"
+	FactUpdate = "update"
+	FactDelete = "delete"
+	FactNoop   = "noop"
+)
+
 type Triplet struct {
-	Subject   string `json:"subject"`
-	Predicate string `json:"predicate"`
-	Object    string `json:"object"`
+	Subject    string   `json:"subject"`
+	Predicate  string   `json:"predicate"`
+	Object     string   `json:"object"`
+	Importance *float64 `json:"importance,omitempty"`
 }
 
-const systemPrompt = `You are a memory extraction system. Given conversation text, extract:
+const systemPrompt = `You are a memory extraction system. You are given the existing memory (current core blocks, related archival facts and related graph relations) followed by new conversation text. Extract:
+
+1. block_updates: Updates to core memory blocks. Labels are: "human" (personal info about the user), "persona" (bot personality), "context" (current project/session context). Only include blocks that need updating. Provide the FULL updated content for each block, not just the diff: start from the block's existing content, keep everything that is still true, and merge in what the conversation adds or corrects.
 
-1. block_updates: Updates to core memory blocks. Labels are: "human" (personal info about the user), "persona" (bot personality), "context" (current project/session context). Only include blocks that need updating. Provide the FULL updated content for each block, not just the diff.
+2. facts: Operations on long-term archival facts. Each fact is a self-contained statement with relevant tags. Compare what the conversation says against the existing archival facts (listed with their [id]) and emit one operation per fact:
+   - "add": a new fact not already stored (omit id).
+   - "update": an existing fact that is now incomplete or wrong; give its id and the FULL corrected content and tags.
+   - "delete": an existing fact the conversation shows is no longer true; give its id.
+   - "noop": an existing fact the conversation merely repeats; give its id. Never add a duplicate of an existing fact.
+   Only use ids from the existing archival facts.
 
-2. facts: Important facts worth remembering long-term. Each fact should be a self-contained statement with relevant tags.
+3. triplets: Entity-relationship triplets (subject, predicate, object) for the knowledge graph. Examples: ("Stuart", "works_on", "Moltbot"), ("Moltbot", "is_a", "Discord bot"). Do not repeat existing relations, and reuse existing entity names where they refer to the same thing.
 
-3. triplets: Entity-relationship triplets (subject, predicate, object) for the knowledge graph. Examples: ("Stuart", "works_on", "Moltbot"), ("Moltbot", "is_a", "Discord bot").
+4. importance: Give each added or updated fact, and each triplet, an "importance" from 0 to 1: how much it will matter in future conversations. Use about 0.9 for lasting core facts (identity, family, long-term goals), 0.5 for ordinary useful facts, and 0.2 or less for passing details. Rarely used low-importance memories are eventually forgotten; 1 means never forget.
 
-4. summary: A concise summary of this conversation.
+5. summary: A concise summary of this conversation.
 
 Return ONLY valid JSON matching this schema:
 {
   "block_updates": [{"label": "string", "content": "string"}],
-  "facts": [{"content": "string", "tags": ["string"]}],
-  "triplets": [{"subject": "string", "predicate": "string", "object": "string"}],
+  "facts": [{"op": "add|update|delete|noop", "id": 0, "content": "string", "tags": ["string"], "importance": 0.5}],
+  "triplets": [{"subject": "string", "predicate": "string", "object": "string", "importance": 0.5}],
   "summary": "string"
 }`
 
 // Config holds settings for the ingest pipeline.
 type Config struct {
-	Provider  string // "anthropic" or "ollama"
-	LLMURL    string // e.g., http://localhost:11434 for Ollama
-	LLMModel  string // e.g., llama3.2, claude-sonnet-4-20250514
-	APIKey    string // for anthropic
+	Provider  string       // provider name, recorded with each run
+	LLMModel  string       // model name, recorded with each run
+	LLM       llm.Provider // performs the extraction
 	EmbedProv embeddings.Provider
+	Quant     embeddings.Quantization // storage encoding for new fact embeddings
+	Source    string                  // recorded on added facts: conversation ID, URL...
+	Metadata  memory.Metadata         // recorded on added facts
 }
 
 // ConfigFromAppConfig creates an ingest Config from the app-level config.
-func ConfigFromAppConfig(provider, model, apiKey, baseURL string, embedProv embeddings.Provider) *Config {
+func ConfigFromAppConfig(provider, model, apiKey, baseURL string, embedProv embeddings.Provider) (*Config, error) {
+	prov, err := llm.New(provider, model, apiKey, baseURL)
+	if err != nil {
+		return nil, err
+	}
 	return &Config{
 		Provider:  provider,
-		LLMURL:    baseURL,
 		LLMModel:  model,
-		APIKey:    apiKey,
+		LLM:       prov,
 		EmbedProv: embedProv,
-	}
+	}, nil
 }
 
 // Run processes conversation text through the LLM and stores extracted information.
 func Run(db *sql.DB, text string, cfg *Config) (*ExtractionResult, error) {
-	if cfg == nil {
+	if cfg == nil || cfg.LLM == nil {
 		return nil, fmt.Errorf("no config provided — run 'botmem init' to set up")
 	}
 
-	var result *ExtractionResult
-	var err error
+	mc, err := gatherContext(db, text, cfg)
+	if err != nil {
+		return nil, err
+	}
+	msg := userMessage(text, mc)
 
-	switch cfg.Provider {
-	case "claude":
-		result, err = extractWithClaude(text)
-	case "anthropic":
-		apiKey := cfg.APIKey
-		if apiKey == "" {
-			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	raw, err := cfg.LLM.Complete(systemPrompt, msg)
+	if err != nil {
+		return nil, fmt.Errorf("extract: %w", err)
+	}
+	result, err := decodeResult(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveFactOps(result.Facts, mc); err != nil {
+		return nil, err
+	}
+
+	// Embed before opening the transaction so no network call holds a write
+	// lock on the database.
+	factEmbeddings := make([][]byte, len(result.Facts))
+	if cfg.EmbedProv != nil {
+		var idx []int
+		var texts []string
+		for i, f := range result.Facts {
+			if f.Op == FactAdd || f.Op == FactUpdate {
+				idx = append(idx, i)
+				texts = append(texts, f.Content)
+			}
 		}
-		if apiKey == "" {
-			return nil, fmt.Errorf("no Anthropic API key — set ANTHROPIC_API_KEY or run 'botmem init'")
+		// A failed batch stores the facts without embeddings.
+		if vecs, err := cfg.EmbedProv.BatchEmbed(texts); err == nil {
+			for j, i := range idx {
+				factEmbeddings[i] = embeddings.SerializeEmbedding(vecs[j])
+			}
 		}
-		result, err = extractWithAnthropic(text, apiKey)
-	case "ollama":
-		result, err = extractWithOllama(text, cfg)
-	default:
-		return nil, fmt.Errorf("unknown provider %q — run 'botmem init' to configure", cfg.Provider)
 	}
+
+	tx, err := db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("extract: %w", err)
+		return nil, fmt.Errorf("begin ingest: %w", err)
 	}
+	defer tx.Rollback()
+
+	sum := sha256.Sum256([]byte(text))
+	run, err := memory.NewRunStore(tx).Create(cfg.Provider, cfg.LLMModel, hex.EncodeToString(sum[:]), raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(tx, run.ID, result, factEmbeddings, cfg); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit ingest: %w", err)
+	}
+
+	result.RunID = run.ID
+	return result, nil
+}
+
+// archivalStore returns an archival store that records, stores and searches
+// embeddings as configured.
+func (c *Config) archivalStore(db memory.DBTX) *memory.ArchivalStore {
+	store := memory.NewArchivalStore(db).WithQuantization(c.Quant)
+	if c.EmbedProv != nil {
+		store = store.WithEmbeddingModel(c.EmbedProv.ModelID())
+	}
+	return store
+}
+
+// apply writes an extraction result to the memory stores and links every
+// write to runID. It is run inside a single transaction so an ingest lands
+// completely or not at all. factEmbeddings were produced by cfg.EmbedProv.
+func apply(tx memory.DBTX, runID int64, result *ExtractionResult, factEmbeddings [][]byte, cfg *Config) error {
+	runs := memory.NewRunStore(tx)
 
 	// Apply block updates
-	blocks := memory.NewBlockStore(db)
+	blocks := memory.NewBlockStore(tx)
 	for _, bu := range result.BlockUpdates {
-		existing, err := blocks.GetByLabel(bu.Label)
-		if err != nil {
+		var previous *string
+		if existing, err := blocks.GetByLabel(bu.Label); err != nil {
 			// Block doesn't exist, create it
 			if _, err := blocks.Create(bu.Label, "core", bu.Content); err != nil {
-				return nil, fmt.Errorf("create block %q: %w", bu.Label, err)
+				return fmt.Errorf("create block %q: %w", bu.Label, err)
 			}
 		} else {
-			_ = existing
+			previous = &existing.Content
 			if _, err := blocks.Update(bu.Label, bu.Content); err != nil {
-				return nil, fmt.Errorf("update block %q: %w", bu.Label, err)
+				return fmt.Errorf("update block %q: %w", bu.Label, err)
 			}
 		}
+		if err := runs.RecordBlockChange(runID, bu.Label, previous, bu.Content); err != nil {
+			return err
+		}
 	}
 
-	// Store facts in archival
-	archival := memory.NewArchivalStore(db)
-	for _, f := range result.Facts {
-		var emb []byte
-		if cfg.EmbedProv != nil {
-			if vec, err := cfg.EmbedProv.Embed(f.Content); err == nil {
-				emb = embeddings.SerializeEmbedding(vec)
+	// Apply fact operations to archival. Updates and deletes record the
+	// entry's previous state so the run can be undone.
+	archival := cfg.archivalStore(tx)
+	for i, f := range result.Facts {
+		switch f.Op {
+		case FactAdd, "":
+			e, err := archival.Insert(memory.NewArchival{
+				Content:    f.Content,
+				Tags:       f.Tags,
+				Source:     cfg.Source,
+				Metadata:   cfg.Metadata,
+				Importance: clampImportance(f.Importance),
+				Embedding:  factEmbeddings[i],
+			})
+			if err != nil {
+				return fmt.Errorf("add fact: %w", err)
 			}
-		}
-		if _, err := archival.Add(f.Content, f.Tags, emb); err != nil {
-			return nil, fmt.Errorf("add fact: %w", err)
+			if err := runs.LinkArchival(runID, e.ID); err != nil {
+				return err
+			}
+		case FactUpdate:
+			prev, err := archival.GetByID(f.ID)
+			if err != nil {
+				return fmt.Errorf("update fact: %w", err)
+			}
+			// Facts updated without tags keep their previous ones.
+			patch := memory.ArchivalPatch{
+				Content:    &f.Content,
+				Importance: clampImportance(f.Importance),
+				Embedding:  factEmbeddings[i],
+			}
+			if len(f.Tags) > 0 {
+				patch.Tags = f.Tags
+			}
+			if _, err := archival.Update(f.ID, patch); err != nil {
+				return fmt.Errorf("update fact: %w", err)
+			}
+			if err := runs.RecordFactChange(runID, FactUpdate, prev, &f.Content); err != nil {
+				return err
+			}
+		case FactDelete:
+			prev, err := archival.GetByID(f.ID)
+			if err != nil {
+				return fmt.Errorf("delete fact: %w", err)
+			}
+			if err := archival.Delete(f.ID); err != nil {
+				return fmt.Errorf("delete fact %d: %w", f.ID, err)
+			}
+			if err := runs.RecordFactChange(runID, FactDelete, prev, nil); err != nil {
+				return err
+			}
+		case FactNoop:
+		default:
+			return fmt.Errorf("unknown fact op %q", f.Op)
 		}
 	}
 
-	// Store triplets in graph
-	graph := memory.NewGraphStore(db)
+	// Store triplets in graph. Relations that already existed keep their
+	// original provenance.
+	graph := memory.NewGraphStore(tx)
 	for _, t := range result.Triplets {
-		if err := graph.AddRelation(t.Subject, t.Predicate, t.Object, ""); err != nil {
-			return nil, fmt.Errorf("add triplet: %w", err)
+		id, created, err := graph.EnsureRelation(t.Subject, t.Predicate, t.Object, "")
+		if err != nil {
+			return fmt.Errorf("add triplet: %w", err)
+		}
+		if created {
+			if err := runs.LinkRelation(runID, id); err != nil {
+				return err
+			}
+			if imp := clampImportance(t.Importance); imp != nil {
+				if err := graph.SetImportance(id, *imp); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
 	// Store summary
 	if result.Summary != "" {
-		summaries := memory.NewSummaryStore(db)
-		if _, err := summaries.Add(0, result.Summary, ""); err != nil {
-			return nil, fmt.Errorf("add summary: %w", err)
+		summaries := memory.NewSummaryStore(tx)
+		sm, err := summaries.Add(0, result.Summary, "")
+		if err != nil {
+			return fmt.Errorf("add summary: %w", err)
+		}
+		if err := runs.LinkSummary(runID, sm.ID); err != nil {
+			return err
 		}
 	}
 
-	return result, nil
+	return nil
 }
 
-func extractWithOllama(text string, cfg *Config) (*ExtractionResult, error) {
-	reqBody, _ := json.Marshal(map[string]any{
-		"model":  cfg.LLMModel,
-		"stream": false,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": text},
-		},
-		"format": "json",
-	})
-
-	resp, err := http.Post(cfg.LLMURL+"/api/chat", "application/json", bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("ollama request: %w", err)
+// clampImportance brings an importance from the LLM into range, so a
+// sloppy score never fails the ingest.
+func clampImportance(importance *float64) *float64 {
+	if importance == nil || math.IsNaN(*importance) {
+		return nil
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ollama: status %d: %s", resp.StatusCode, body)
-	}
-
-	var ollamaResp struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	}
-	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return nil, fmt.Errorf("decode ollama response: %w", err)
-	}
-
-	var result ExtractionResult
-	if err := json.Unmarshal([]byte(ollamaResp.Message.Content), &result); err != nil {
-		return nil, fmt.Errorf("decode extraction result: %w\nraw: %s", err, ollamaResp.Message.Content)
-	}
-	return &result, nil
+	v := min(max(*importance, 0), 1)
+	return &v
 }
 
-func extractWithAnthropic(text, apiKey string) (*ExtractionResult, error) {
-	reqBody, _ := json.Marshal(map[string]any{
-		"model":      "claude-sonnet-4-20250514",
-		"max_tokens": 4096,
-		"system":     systemPrompt,
-		"messages": []map[string]string{
-			{"role": "user", "content": text},
-		},
-	})
-
-	req, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("anthropic request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("anthropic: status %d: %s", resp.StatusCode, body)
-	}
-
-	var anthropicResp struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-	}
-	if err := json.Unmarshal(body, &anthropicResp); err != nil {
-		return nil, fmt.Errorf("decode anthropic response: %w", err)
-	}
-	if len(anthropicResp.Content) == 0 {
-		return nil, fmt.Errorf("empty anthropic response")
+// resolveFactOps normalizes each fact's operation and checks its ID against
+// the facts the LLM was shown, so a hallucinated ID can never touch an
+// unrelated entry. An update of an unknown ID is kept as an add; a delete or
+// noop of one is dropped to a noop.
+func resolveFactOps(facts []Fact, mc *MemoryContext) error {
+	shown := map[int64]bool{}
+	if mc != nil {
+		for _, e := range mc.Facts {
+			shown[e.ID] = true
+		}
 	}
-
-	var result ExtractionResult
-	if err := json.Unmarshal([]byte(anthropicResp.Content[0].Text), &result); err != nil {
-		return nil, fmt.Errorf("decode extraction result: %w\nraw: %s", err, anthropicResp.Content[0].Text)
+	for i := range facts {
+		f := &facts[i]
+		f.Op = strings.ToLower(strings.TrimSpace(f.Op))
+		switch f.Op {
+		case "", FactAdd:
+			f.Op, f.ID = FactAdd, 0
+		case FactUpdate:
+			if !shown[f.ID] {
+				f.Op, f.ID = FactAdd, 0
+			}
+		case FactDelete, FactNoop:
+			if !shown[f.ID] {
+				f.Op = FactNoop
+			}
+		default:
+			return fmt.Errorf("unknown fact op %q", f.Op)
+		}
 	}
-	return &result, nil
+	return nil
 }
 
-func extractWithClaude(text string) (*ExtractionResult, error) {
-	// Build the full prompt: system instructions + user text
-	prompt := systemPrompt + "\n\nConversation text to extract from:\n\n" + text
-
-	cmd := exec.Command("claude", "-p", "--output-format", "text", prompt)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("claude -p failed: %w\nstderr: %s", err, stderr.String())
-	}
-
-	output := strings.TrimSpace(stdout.String())
-	if output == "" {
-		return nil, fmt.Errorf("empty response from claude -p")
-	}
-
-	// Claude may wrap JSON in markdown code fences — strip them
-	output = stripCodeFences(output)
+// decodeResult parses the LLM's raw output into an ExtractionResult.
+func decodeResult(raw string) (*ExtractionResult, error) {
+	// Models may wrap JSON in markdown code fences — strip them
+	output := stripCodeFences(raw)
 
 	var result ExtractionResult
 	if err := json.Unmarshal([]byte(output), &result); err != nil {
-		return nil, fmt.Errorf("decode extraction result: %w\nraw: %s", err, output)
+		return nil, fmt.Errorf("decode extraction result: %w\nraw: %s", err, raw)
 	}
 	return &result, nil
 }
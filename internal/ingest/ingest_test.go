@@ -0,0 +1,385 @@
+package ingest
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stukennedy/botmem/internal/db"
+	"github.com/stukennedy/botmem/internal/embeddings"
+	"github.com/stukennedy/botmem/internal/memory"
+)
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// applyInTx mirrors Run's storage phase without calling an LLM and returns
+// the new run's ID.
+func applyInTx(t *testing.T, database *sql.DB, result *ExtractionResult) (int64, error) {
+	t.Helper()
+	tx, err := database.Begin()
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback()
+	run, err := memory.NewRunStore(tx).Create("test", "test-model", "hash", `{"raw":true}`)
+	if err != nil {
+		return 0, err
+	}
+	if err := apply(tx, run.ID, result, make([][]byte, len(result.Facts)), &Config{}); err != nil {
+		return 0, err
+	}
+	return run.ID, tx.Commit()
+}
+
+func TestApply_WritesAllStores(t *testing.T) {
+	database := testDB(t)
+	result := &ExtractionResult{
+		BlockUpdates: []BlockUpdate{{Label: "human", Content: "Stuart"}},
+		Facts:        []Fact{{Content: "Stuart prefers Go", Tags: []string{"preference"}}},
+		Triplets:     []Triplet{{Subject: "Stuart", Predicate: "uses", Object: "Go"}},
+		Summary:      "Talked about Go",
+	}
+	if _, err := applyInTx(t, database, result); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if b, err := memory.NewBlockStore(database).GetByLabel("human"); err != nil || b.Content != "Stuart" {
+		t.Errorf("block not written: %v", err)
+	}
+	if entries, _ := memory.NewArchivalStore(database).List("", 10); len(entries) != 1 {
+		t.Errorf("expected 1 fact, got %d", len(entries))
+	}
+	if rels, _ := memory.NewGraphStore(database).QueryEntity("Stuart"); len(rels) != 1 {
+		t.Errorf("expected 1 relation, got %d", len(rels))
+	}
+	if n, _ := memory.NewSummaryStore(database).CountAtLevel(0); n != 1 {
+		t.Errorf("expected 1 summary, got %d", n)
+	}
+}
+
+func TestApply_FailureLeavesDatabaseUntouched(t *testing.T) {
+	database := testDB(t)
+	memory.NewBlockStore(database).Create("human", "core", "original")
+
+	// Make the triplet step fail after blocks and facts have been written.
+	if _, err := database.Exec(`CREATE TRIGGER fail_relations BEFORE INSERT ON relations
+		BEGIN SELECT RAISE(ABORT, 'bad triplet'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &ExtractionResult{
+		BlockUpdates: []BlockUpdate{{Label: "human", Content: "clobbered"}},
+		Facts:        []Fact{{Content: "should not persist"}},
+		Triplets:     []Triplet{{Subject: "A", Predicate: "knows", Object: "B"}},
+		Summary:      "should not persist",
+	}
+	if _, err := applyInTx(t, database, result); err == nil {
+		t.Fatal("expected apply to fail")
+	}
+
+	if b, _ := memory.NewBlockStore(database).GetByLabel("human"); b.Content != "original" {
+		t.Errorf("block update should have rolled back, got %q", b.Content)
+	}
+	if entries, _ := memory.NewArchivalStore(database).List("", 10); len(entries) != 0 {
+		t.Errorf("expected facts rolled back, got %d", len(entries))
+	}
+	if entities, _ := memory.NewGraphStore(database).ListEntities(""); len(entities) != 0 {
+		t.Errorf("expected entities rolled back, got %d", len(entities))
+	}
+	if n, _ := memory.NewSummaryStore(database).CountAtLevel(0); n != 0 {
+		t.Errorf("expected summary rolled back, got %d", n)
+	}
+	if runs, _ := memory.NewRunStore(database).List(10); len(runs) != 0 {
+		t.Errorf("expected run record rolled back, got %d", len(runs))
+	}
+}
+
+func TestApply_RecordsProvenance(t *testing.T) {
+	database := testDB(t)
+	memory.NewBlockStore(database).Create("human", "core", "before")
+	memory.NewGraphStore(database).AddRelation("Stuart", "uses", "Go", "")
+
+	runID, err := applyInTx(t, database, &ExtractionResult{
+		BlockUpdates: []BlockUpdate{{Label: "human", Content: "after"}, {Label: "context", Content: "new"}},
+		Facts:        []Fact{{Content: "Stuart prefers Go"}},
+		Triplets: []Triplet{
+			{Subject: "Stuart", Predicate: "uses", Object: "Go"}, // already existed
+			{Subject: "Stuart", Predicate: "lives_in", Object: "NZ"},
+		},
+		Summary: "Talked about Go",
+	})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	items, err := memory.NewRunStore(database).Items(runID)
+	if err != nil {
+		t.Fatalf("items: %v", err)
+	}
+	if len(items.Facts) != 1 || len(items.Summaries) != 1 {
+		t.Errorf("expected 1 fact and 1 summary, got %d and %d", len(items.Facts), len(items.Summaries))
+	}
+	if len(items.Relations) != 1 || items.Relations[0].Predicate != "lives_in" {
+		t.Errorf("expected only the new relation linked to the run, got %+v", items.Relations)
+	}
+	if len(items.BlockChanges) != 2 {
+		t.Fatalf("expected 2 block changes, got %d", len(items.BlockChanges))
+	}
+	if prev := items.BlockChanges[0].PreviousContent; prev == nil || *prev != "before" {
+		t.Errorf("expected previous content recorded for updated block, got %v", prev)
+	}
+	if items.BlockChanges[1].PreviousContent != nil {
+		t.Error("expected nil previous content for created block")
+	}
+}
+
+func TestGatherContext_FindsRelatedMemory(t *testing.T) {
+	database := testDB(t)
+	memory.NewBlockStore(database).Create("human", "core", "Stuart, lives in Glasgow")
+	archival := memory.NewArchivalStore(database)
+	archival.Add("Stuart prefers Outside IR35 contracts", []string{"work"}, nil)
+	archival.Add("The cat is called Biscuit", nil, nil)
+	graph := memory.NewGraphStore(database)
+	graph.AddRelation("Stuart", "works_on", "botmem", "")
+	graph.AddRelation("Alice", "knows", "Bob", "")
+
+	text := `Stuart said: "contracts" should be Outside IR35 (again!)`
+	mc, err := gatherContext(database, text, &Config{})
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	if len(mc.Blocks) != 1 || mc.Blocks[0].Label != "human" {
+		t.Errorf("expected the human block, got %+v", mc.Blocks)
+	}
+	if len(mc.Facts) != 1 || mc.Facts[0].Content != "Stuart prefers Outside IR35 contracts" {
+		t.Errorf("expected the IR35 fact only, got %+v", mc.Facts)
+	}
+	if len(mc.Relations) != 1 || mc.Relations[0].Object != "botmem" {
+		t.Errorf("expected Stuart's relation only, got %+v", mc.Relations)
+	}
+
+	msg := userMessage(text, mc)
+	for _, want := range []string{
+		"Stuart, lives in Glasgow",
+		fmt.Sprintf("[%d] Stuart prefers Outside IR35 contracts (tags: work)", mc.Facts[0].ID),
+		"(Stuart, works_on, botmem)",
+		text,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("user message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestKeywordQuery(t *testing.T) {
+	if got := keywordQuery(`C++ "rollout AND what's next?`); got != `"rollout" OR "and" OR "what" OR "next"` {
+		t.Errorf("unexpected query %q", got)
+	}
+	if got := keywordQuery("a b ?!"); got != "" {
+		t.Errorf("expected empty query for short words, got %q", got)
+	}
+}
+
+// recordingEmbedder wraps a provider and keeps the last text it embedded.
+type recordingEmbedder struct {
+	embeddings.Provider
+	text string
+}
+
+func (r *recordingEmbedder) Embed(text string) ([]float32, error) {
+	r.text = text
+	return r.Provider.Embed(text)
+}
+
+func TestRelatedFacts_TruncatesEmbedTextAtRuneBoundary(t *testing.T) {
+	database := testDB(t)
+	prov := &recordingEmbedder{Provider: embeddings.NewLocalProvider(0)}
+	text := "a" + strings.Repeat("€", maxEmbedQueryText)
+	if _, err := relatedFacts(database, text, &Config{EmbedProv: prov}); err != nil {
+		t.Fatalf("related facts: %v", err)
+	}
+	if len(prov.text) > maxEmbedQueryText || len(prov.text) < maxEmbedQueryText-3 || !utf8.ValidString(prov.text) {
+		t.Errorf("expected valid UTF-8 of at most %d bytes, got %d bytes (valid %v)", maxEmbedQueryText, len(prov.text), utf8.ValidString(prov.text))
+	}
+}
+
+func TestApply_FactOperations(t *testing.T) {
+	database := testDB(t)
+	archival := memory.NewArchivalStore(database)
+	stale, _ := archival.Add("Stuart prefers Inside IR35", []string{"work"}, nil)
+	wrong, _ := archival.Add("Stuart lives in London", nil, nil)
+	same, _ := archival.Add("Stuart likes Go", nil, nil)
+
+	runID, err := applyInTx(t, database, &ExtractionResult{Facts: []Fact{
+		{Op: FactUpdate, ID: stale.ID, Content: "Stuart prefers Outside IR35"},
+		{Op: FactDelete, ID: wrong.ID},
+		{Op: FactNoop, ID: same.ID},
+		{Op: FactAdd, Content: "Stuart lives in Glasgow"},
+	}})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if e, _ := archival.GetByID(stale.ID); e.Content != "Stuart prefers Outside IR35" || e.Tags != "work" {
+		t.Errorf("expected update in place with tags kept, got %+v", e)
+	}
+	if _, err := archival.GetByID(wrong.ID); err == nil {
+		t.Error("expected deleted fact to be gone")
+	}
+	if entries, _ := archival.List("", 10); len(entries) != 3 {
+		t.Errorf("expected 3 facts, got %d", len(entries))
+	}
+
+	items, _ := memory.NewRunStore(database).Items(runID)
+	if len(items.FactChanges) != 2 || len(items.Facts) != 1 {
+		t.Fatalf("expected 2 fact changes and 1 added fact, got %d and %d", len(items.FactChanges), len(items.Facts))
+	}
+
+	res, err := memory.NewRunStore(database).Undo(runID)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if res.FactsRestored != 2 || res.Facts != 1 {
+		t.Errorf("unexpected undo result: %+v", res)
+	}
+	if e, _ := archival.GetByID(stale.ID); e.Content != "Stuart prefers Inside IR35" {
+		t.Errorf("expected update reverted, got %q", e.Content)
+	}
+	if e, err := archival.GetByID(wrong.ID); err != nil || e.Content != "Stuart lives in London" {
+		t.Errorf("expected delete reverted under the same ID, got %v, %v", e, err)
+	}
+}
+
+func TestResolveFactOps_OnlyTouchesShownFacts(t *testing.T) {
+	mc := &MemoryContext{Facts: []*memory.ArchivalEntry{{ID: 7}}}
+	facts := []Fact{
+		{Content: "new"},
+		{Op: "UPDATE", ID: 7, Content: "fixed"},
+		{Op: FactUpdate, ID: 99, Content: "hallucinated"},
+		{Op: FactDelete, ID: 99},
+	}
+	if err := resolveFactOps(facts, mc); err != nil {
+		t.Fatal(err)
+	}
+	want := []Fact{
+		{Op: FactAdd, Content: "new"},
+		{Op: FactUpdate, ID: 7, Content: "fixed"},
+		{Op: FactAdd, Content: "hallucinated"},
+		{Op: FactNoop, ID: 99},
+	}
+	for i := range want {
+		if facts[i].Op != want[i].Op || facts[i].ID != want[i].ID {
+			t.Errorf("fact %d: expected %s/%d, got %s/%d", i, want[i].Op, want[i].ID, facts[i].Op, facts[i].ID)
+		}
+	}
+	if err := resolveFactOps([]Fact{{Op: "merge"}}, mc); err == nil {
+		t.Error("expected error for unknown op")
+	}
+}
+
+// fakeLLM returns a canned reply and records the prompt it was sent.
+type fakeLLM struct {
+	reply string
+	user  string
+}
+
+func (f *fakeLLM) Complete(system, user string) (string, error) {
+	f.user = user
+	return f.reply, nil
+}
+
+func TestRun_UsesProvider(t *testing.T) {
+	database := testDB(t)
+	memory.NewBlockStore(database).Create("human", "core", "Stuart")
+	fake := &fakeLLM{reply: "```json\n" + `{"facts":[{"op":"add","content":"Stuart uses vLLM"}],"summary":"s"}` + "\n```"}
+
+	result, err := Run(database, "Stuart set up vLLM", &Config{Provider: "openai", LLMModel: "qwen", LLM: fake})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(fake.user, "Stuart set up vLLM") || !strings.Contains(fake.user, "### human\nStuart") {
+		t.Errorf("prompt missing text or existing blocks:\n%s", fake.user)
+	}
+	run, err := memory.NewRunStore(database).GetByID(result.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Provider != "openai" || run.Model != "qwen" {
+		t.Errorf("unexpected run provenance: %+v", run)
+	}
+	if entries, _ := memory.NewArchivalStore(database).List("", 10); len(entries) != 1 {
+		t.Errorf("expected 1 fact, got %d", len(entries))
+	}
+}
+
+func TestRun_StoresImportance(t *testing.T) {
+	database := testDB(t)
+	fake := &fakeLLM{reply: `{"facts":[{"op":"add","content":"Stuart was born in Glasgow","importance":0.9},` +
+		`{"op":"add","content":"Stuart had toast","importance":7},{"op":"add","content":"Stuart uses Go"}],` +
+		`"triplets":[{"subject":"Stuart","predicate":"born_in","object":"Glasgow","importance":0.95}]}`}
+
+	if _, err := Run(database, "text", &Config{LLM: fake}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	want := map[string]float64{
+		"Stuart was born in Glasgow": 0.9,
+		"Stuart had toast":           1, // clamped
+		"Stuart uses Go":             memory.DefaultImportance,
+	}
+	entries, _ := memory.NewArchivalStore(database).List("", 10)
+	for _, e := range entries {
+		if e.Importance != want[e.Content] {
+			t.Errorf("%q: expected importance %g, got %g", e.Content, want[e.Content], e.Importance)
+		}
+	}
+	if rels, _ := memory.NewGraphStore(database).QueryEntity("Glasgow"); len(rels) != 1 || rels[0].Importance != 0.95 {
+		t.Errorf("expected the relation's importance stored, got %+v", rels)
+	}
+}
+
+func TestRun_ContextReadsAreNotAccesses(t *testing.T) {
+	database := testDB(t)
+	prov := embeddings.NewLocalProvider(0)
+	vec, _ := prov.Embed("Stuart lives in Glasgow")
+	fact, err := memory.NewArchivalStore(database).WithEmbeddingModel(prov.ModelID()).Insert(memory.NewArchival{
+		Content: "Stuart lives in Glasgow", Embedding: embeddings.SerializeEmbedding(vec),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := memory.NewGraphStore(database)
+	if err := graph.AddRelation("Stuart", "lives_in", "Glasgow", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cfg := range []*Config{{}, {EmbedProv: prov}} {
+		fake := &fakeLLM{reply: `{"facts":[]}`}
+		cfg.LLM = fake
+		if _, err := Run(database, "Stuart moved away from Glasgow", cfg); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		if !strings.Contains(fake.user, "Stuart lives in Glasgow") || !strings.Contains(fake.user, "(Stuart, lives_in, Glasgow)") {
+			t.Fatalf("expected the fact and relation in the prompt:\n%s", fake.user)
+		}
+	}
+
+	got, _ := memory.NewArchivalStore(database).GetByID(fact.ID)
+	if got.AccessCount != 0 || got.LastAccessedAt != nil {
+		t.Errorf("expected ingest not to count as a fact access, got %+v", got.Access)
+	}
+	rels, _ := graph.ListRelations(10)
+	if len(rels) != 1 || rels[0].AccessCount != 0 || rels[0].LastAccessedAt != nil {
+		t.Errorf("expected ingest not to count as a relation access, got %+v", rels)
+	}
+}
@@ -0,0 +1,185 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stukennedy/botmem/internal/memory"
+)
+
+const (
+	maxRelatedFacts   = 20
+	maxQueryTerms     = 64
+	maxEmbedQueryText = 8000 // bytes of conversation text embedded for retrieval
+)
+
+// MemoryContext is the existing memory shown to the LLM alongside the
+// conversation, so block updates merge with what is already known and facts
+// already stored are not extracted again.
+type MemoryContext struct {
+	Blocks    []*memory.Block
+	Facts     []*memory.ArchivalEntry
+	Relations []*memory.Relation
+}
+
+// gatherContext loads the core blocks plus the archival facts and graph
+// relations most related to text. Facts are retrieved with hybrid search
+// when an embeddings provider is configured, else with full-text search.
+// Showing memories to the extractor isn't a recall, so none of these reads
+// count as accesses.
+func gatherContext(db memory.DBTX, text string, cfg *Config) (*MemoryContext, error) {
+	mc := &MemoryContext{}
+	var err error
+	if mc.Blocks, err = memory.NewBlockStore(db).List("core"); err != nil {
+		return nil, fmt.Errorf("load core blocks: %w", err)
+	}
+	if mc.Facts, err = relatedFacts(db, text, cfg); err != nil {
+		return nil, err
+	}
+	if mc.Relations, err = relatedRelations(db, text); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+func relatedFacts(db memory.DBTX, text string, cfg *Config) ([]*memory.ArchivalEntry, error) {
+	query := keywordQuery(text)
+	archival := cfg.archivalStore(db).Untracked()
+
+	if cfg.EmbedProv != nil {
+		embedText := text
+		if len(embedText) > maxEmbedQueryText {
+			cut := maxEmbedQueryText
+			for !utf8.RuneStart(embedText[cut]) {
+				cut--
+			}
+			embedText = embedText[:cut]
+		}
+		// A failed embedding falls back to full-text retrieval.
+		if vec, err := cfg.EmbedProv.Embed(embedText); err == nil {
+			var results []*memory.SearchResult
+			if query == "" {
+				results, err = archival.SearchSemantic(vec, maxRelatedFacts)
+			} else {
+				results, err = archival.SearchHybrid(query, vec, maxRelatedFacts, memory.HybridWeights{})
+			}
+			if err != nil {
+				return nil, fmt.Errorf("retrieve related facts: %w", err)
+			}
+			return entries(results), nil
+		}
+	}
+
+	if query == "" {
+		return nil, nil
+	}
+	results, err := archival.Search(query, maxRelatedFacts)
+	if err != nil {
+		return nil, fmt.Errorf("retrieve related facts: %w", err)
+	}
+	return entries(results), nil
+}
+
+// entries drops the scores from search results.
+func entries(results []*memory.SearchResult) []*memory.ArchivalEntry {
+	facts := make([]*memory.ArchivalEntry, len(results))
+	for i, r := range results {
+		facts[i] = r.ArchivalEntry
+	}
+	return facts
+}
+
+// relatedRelations returns the relations of every known entity whose name
+// appears in text.
+func relatedRelations(db memory.DBTX, text string) ([]*memory.Relation, error) {
+	graph := memory.NewGraphStore(db).Untracked()
+	entities, err := graph.ListEntities("")
+	if err != nil {
+		return nil, fmt.Errorf("load entities: %w", err)
+	}
+
+	lower := strings.ToLower(text)
+	seen := map[int64]bool{}
+	var rels []*memory.Relation
+	for _, e := range entities {
+		if e.Name == "" || !strings.Contains(lower, strings.ToLower(e.Name)) {
+			continue
+		}
+		found, err := graph.QueryEntity(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range found {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				rels = append(rels, r)
+			}
+		}
+	}
+	return rels, nil
+}
+
+// keywordQuery turns free text into an FTS5 query that matches any of its
+// words of three or more letters. Each word is quoted by memory.PlainQuery,
+// so FTS5 operators in the text are searched for as words.
+func keywordQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := map[string]bool{}
+	var terms []string
+	for _, w := range words {
+		if len([]rune(w)) < 3 || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, memory.PlainQuery(w))
+		if len(terms) == maxQueryTerms {
+			break
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// userMessage renders the existing memory followed by the conversation text.
+func userMessage(text string, mc *MemoryContext) string {
+	var b strings.Builder
+	b.WriteString("Existing memory:\n")
+
+	b.WriteString("\n## Core blocks\n")
+	if mc == nil || len(mc.Blocks) == 0 {
+		b.WriteString("(none)\n")
+	} else {
+		for _, bl := range mc.Blocks {
+			fmt.Fprintf(&b, "### %s\n%s\n", bl.Label, bl.Content)
+		}
+	}
+
+	b.WriteString("\n## Related archival facts\n")
+	if mc == nil || len(mc.Facts) == 0 {
+		b.WriteString("(none)\n")
+	} else {
+		for _, f := range mc.Facts {
+			fmt.Fprintf(&b, "- [%d] %s", f.ID, f.Content)
+			if f.Tags != "" {
+				fmt.Fprintf(&b, " (tags: %s)", f.Tags)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n## Related graph relations\n")
+	if mc == nil || len(mc.Relations) == 0 {
+		b.WriteString("(none)\n")
+	} else {
+		for _, r := range mc.Relations {
+			fmt.Fprintf(&b, "- (%s, %s, %s)\n", r.Subject, r.Predicate, r.Object)
+		}
+	}
+
+	b.WriteString("\nConversation text to extract from:\n\n")
+	b.WriteString(text)
+	return b.String()
+}
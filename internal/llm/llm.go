@@ -0,0 +1,199 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Provider sends a system prompt and a user message to an LLM and returns
+// the text of its reply.
+type Provider interface {
+	Complete(system, user string) (string, error)
+}
+
+// Default models and URLs used when the config leaves them empty.
+const (
+	DefaultAnthropicModel = "claude-sonnet-4-20250514"
+	DefaultAnthropicURL   = "https://api.anthropic.com"
+	DefaultOllamaModel    = "llama3.2"
+	DefaultOllamaURL      = "http://localhost:11434"
+	DefaultOpenAIURL      = "http://localhost:8080"
+)
+
+// Providers lists the provider names accepted by New.
+var Providers = []string{"claude", "anthropic", "ollama", "openai"}
+
+// New builds the provider called name. API keys left empty are read from
+// ANTHROPIC_API_KEY or OPENAI_API_KEY respectively.
+func New(name, model, apiKey, baseURL string) (Provider, error) {
+	switch name {
+	case "claude":
+		return &ClaudeCLIProvider{}, nil
+	case "anthropic":
+		if apiKey == "" {
+			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("no Anthropic API key — set ANTHROPIC_API_KEY or run 'botmem init'")
+		}
+		return NewAnthropicProvider(baseURL, model, apiKey), nil
+	case "ollama":
+		return NewOllamaProvider(baseURL, model), nil
+	case "openai":
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		return NewOpenAIProvider(baseURL, model, apiKey), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q — run 'botmem init' to configure", name)
+	}
+}
+
+// ClaudeCLIProvider shells out to Claude Code (claude -p), using whatever
+// model and credentials it is logged in with.
+type ClaudeCLIProvider struct{}
+
+func (p *ClaudeCLIProvider) Complete(system, user string) (string, error) {
+	// claude -p takes a single prompt: system instructions + user message
+	prompt := system + "\n\n" + user
+
+	cmd := exec.Command("claude", "-p", "--output-format", "text", prompt)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("claude -p failed: %w\nstderr: %s", err, stderr.String())
+	}
+
+	output := strings.TrimSpace(stdout.String())
+	if output == "" {
+		return "", fmt.Errorf("empty response from claude -p")
+	}
+	return output, nil
+}
+
+// AnthropicProvider calls the Anthropic Messages API.
+type AnthropicProvider struct {
+	BaseURL string
+	Model   string
+	APIKey  string
+}
+
+func NewAnthropicProvider(baseURL, model, apiKey string) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = DefaultAnthropicURL
+	}
+	if model == "" {
+		model = DefaultAnthropicModel
+	}
+	return &AnthropicProvider{BaseURL: strings.TrimSuffix(baseURL, "/"), Model: model, APIKey: apiKey}
+}
+
+func (p *AnthropicProvider) Complete(system, user string) (string, error) {
+	reqBody, _ := json.Marshal(map[string]any{
+		"model":      p.Model,
+		"max_tokens": 4096,
+		"system":     system,
+		"messages": []map[string]string{
+			{"role": "user", "content": user},
+		},
+	})
+
+	req, err := http.NewRequest("POST", p.BaseURL+"/v1/messages", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	body, err := do(req, "anthropic")
+	if err != nil {
+		return "", err
+	}
+
+	var anthropicResp struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return "", fmt.Errorf("decode anthropic response: %w", err)
+	}
+	if len(anthropicResp.Content) == 0 {
+		return "", fmt.Errorf("empty anthropic response")
+	}
+	return anthropicResp.Content[0].Text, nil
+}
+
+// OllamaProvider calls a local Ollama server's chat API in JSON mode.
+type OllamaProvider struct {
+	BaseURL string
+	Model   string
+}
+
+func NewOllamaProvider(baseURL, model string) *OllamaProvider {
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
+	}
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+	return &OllamaProvider{BaseURL: strings.TrimSuffix(baseURL, "/"), Model: model}
+}
+
+func (p *OllamaProvider) Complete(system, user string) (string, error) {
+	reqBody, _ := json.Marshal(map[string]any{
+		"model":  p.Model,
+		"stream": false,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+		"format": "json",
+	})
+
+	req, err := http.NewRequest("POST", p.BaseURL+"/api/chat", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	body, err := do(req, "ollama")
+	if err != nil {
+		return "", err
+	}
+
+	var ollamaResp struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return "", fmt.Errorf("decode ollama response: %w", err)
+	}
+	return ollamaResp.Message.Content, nil
+}
+
+// do sends req and returns the response body, treating any status other
+// than 200 as an error.
+func do(req *http.Request, name string) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d: %s", name, resp.StatusCode, body)
+	}
+	return body, nil
+}
@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeServer records the last request path, headers and JSON body, and
+// replies with reply.
+func fakeServer(t *testing.T, reply string) (*httptest.Server, *http.Request, map[string]any) {
+	t.Helper()
+	var last http.Request
+	body := map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = *r
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &last, body
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	srv, req, body := fakeServer(t, `{"choices":[{"message":{"content":"{\"summary\":\"hi\"}"}}]}`)
+
+	for _, base := range []string{srv.URL, srv.URL + "/v1", srv.URL + "/v1/"} {
+		p := NewOpenAIProvider(base, "qwen", "sk-local")
+		out, err := p.Complete("system", "user")
+		if err != nil {
+			t.Fatalf("%s: complete: %v", base, err)
+		}
+		if out != `{"summary":"hi"}` {
+			t.Errorf("unexpected output %q", out)
+		}
+		if req.URL.Path != "/v1/chat/completions" {
+			t.Errorf("%s: expected /v1/chat/completions, got %s", base, req.URL.Path)
+		}
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer sk-local" {
+		t.Errorf("expected bearer auth, got %q", got)
+	}
+	if body["model"] != "qwen" {
+		t.Errorf("expected model qwen, got %v", body["model"])
+	}
+	if msgs, _ := body["messages"].([]any); len(msgs) != 2 {
+		t.Errorf("expected system and user messages, got %v", body["messages"])
+	}
+}
+
+func TestOpenAIProvider_NoKeyNoAuthHeader(t *testing.T) {
+	srv, req, _ := fakeServer(t, `{"choices":[{"message":{"content":"ok"}}]}`)
+	if _, err := NewOpenAIProvider(srv.URL, "", "").Complete("s", "u"); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Authorization"); got != "" {
+		t.Errorf("expected no auth header, got %q", got)
+	}
+}
+
+func TestOpenAIProvider_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	if _, err := NewOpenAIProvider(srv.URL, "", "").Complete("s", "u"); err == nil {
+		t.Error("expected error for non-200 status")
+	}
+}
+
+func TestAnthropicProvider_UsesConfiguredModel(t *testing.T) {
+	srv, req, body := fakeServer(t, `{"content":[{"text":"ok"}]}`)
+	out, err := NewAnthropicProvider(srv.URL, "claude-opus-4", "sk-ant").Complete("s", "u")
+	if err != nil || out != "ok" {
+		t.Fatalf("complete: %q, %v", out, err)
+	}
+	if body["model"] != "claude-opus-4" {
+		t.Errorf("expected configured model, got %v", body["model"])
+	}
+	if req.URL.Path != "/v1/messages" || req.Header.Get("x-api-key") != "sk-ant" {
+		t.Errorf("unexpected request %s key=%q", req.URL.Path, req.Header.Get("x-api-key"))
+	}
+}
+
+func TestOllamaProvider(t *testing.T) {
+	srv, req, body := fakeServer(t, `{"message":{"content":"ok"}}`)
+	out, err := NewOllamaProvider(srv.URL, "llama3.2").Complete("s", "u")
+	if err != nil || out != "ok" {
+		t.Fatalf("complete: %q, %v", out, err)
+	}
+	if req.URL.Path != "/api/chat" || body["format"] != "json" {
+		t.Errorf("unexpected request %s %v", req.URL.Path, body)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if _, err := New("anthropic", "", "", ""); err == nil {
+		t.Error("expected error without an Anthropic key")
+	}
+	if _, err := New("gpt", "", "", ""); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+	p, err := New("openai", "m", "", "http://localhost:1234/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := p.(*OpenAIProvider); !ok || o.BaseURL != "http://localhost:1234/v1" {
+		t.Errorf("unexpected provider %+v", p)
+	}
+}
@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider speaks the OpenAI /v1/chat/completions protocol, as served
+// by OpenAI itself and by llama.cpp server, vLLM and LM Studio.
+type OpenAIProvider struct {
+	BaseURL string // with or without the trailing /v1
+	Model   string // may be empty for servers that host a single model
+	APIKey  string // optional for local servers
+}
+
+func NewOpenAIProvider(baseURL, model, apiKey string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIURL
+	}
+	return &OpenAIProvider{BaseURL: strings.TrimSuffix(baseURL, "/"), Model: model, APIKey: apiKey}
+}
+
+// endpoint returns the chat completions URL, accepting base URLs given
+// either as the server root or as its /v1 prefix.
+func (p *OpenAIProvider) endpoint() string {
+	if strings.HasSuffix(p.BaseURL, "/v1") {
+		return p.BaseURL + "/chat/completions"
+	}
+	return p.BaseURL + "/v1/chat/completions"
+}
+
+func (p *OpenAIProvider) Complete(system, user string) (string, error) {
+	reqBody, _ := json.Marshal(map[string]any{
+		"model":  p.Model,
+		"stream": false,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+	})
+
+	req, err := http.NewRequest("POST", p.endpoint(), bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	body, err := do(req, "openai")
+	if err != nil {
+		return "", err
+	}
+
+	var openaiResp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &openaiResp); err != nil {
+		return "", fmt.Errorf("decode openai response: %w", err)
+	}
+	if len(openaiResp.Choices) == 0 {
+		return "", fmt.Errorf("empty openai response")
+	}
+	return openaiResp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/stukennedy/botmem/internal/embeddings"
+)
+
+// The ANN index is an IVF (inverted file) index: archival embeddings are
+// clustered around centroids, and a query only scores the rows in the lists
+// of its nearest centroids. Centroids and list assignments live in SQLite
+// next to the archival table so the index persists with the database.
+const (
+	annMaxLists       = 1024
+	annTrainPerList   = 64 // training sample size per centroid
+	annIterations     = 10
+	annMinProbes      = 8
+	annMaxGrowthRatio = 2 // rebuild once the archive doubles since the last build
+)
+
+// ANNStatus describes the state of the ANN index.
+type ANNStatus struct {
+	Built    bool      `json:"built"`
+	Dim      int       `json:"dim,omitempty"`
+	Lists    int       `json:"lists"`
+	Indexed  int       `json:"indexed"`
+	Embedded int       `json:"embedded"`
+	BuiltAt  time.Time `json:"built_at"`
+	Stale    bool      `json:"stale"`
+}
+
+// ANNIndex reads and maintains the index. It loads the centroids once and
+// keeps them until its own Rebuild, so use a fresh one wherever another
+// index may have rebuilt since.
+type ANNIndex struct {
+	db     DBTX
+	loaded [][]float32 // centroids, once loaded; see centroids
+}
+
+func NewANNIndex(db DBTX) *ANNIndex {
+	return &ANNIndex{db: db}
+}
+
+// Status reports whether the index exists and is still usable. The index is
+// stale when embedded rows of its dimension are missing from it, or when the
+// archive has grown well past the size the centroids were trained on.
+func (x *ANNIndex) Status() (*ANNStatus, error) {
+	st := &ANNStatus{}
+	var builtCount int
+	err := x.db.QueryRow(`SELECT dim, built_count, built_at FROM ann_meta WHERE id = 1`).
+		Scan(&st.Dim, &builtCount, &st.BuiltAt)
+	if err == sql.ErrNoRows {
+		err = x.db.QueryRow(`SELECT COUNT(*) FROM archival WHERE embedding IS NOT NULL`).Scan(&st.Embedded)
+		return st, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ann status: %w", err)
+	}
+	st.Built = true
+
+	if err := x.db.QueryRow(`SELECT COUNT(*) FROM ann_centroids`).Scan(&st.Lists); err != nil {
+		return nil, fmt.Errorf("count centroids: %w", err)
+	}
+	if err := x.db.QueryRow(`SELECT COUNT(*) FROM ann_assignments`).Scan(&st.Indexed); err != nil {
+		return nil, fmt.Errorf("count assignments: %w", err)
+	}
+	if err := x.db.QueryRow(
+		`SELECT COUNT(*) FROM archival WHERE embedding IS NOT NULL AND embedding_dim = ?`, st.Dim,
+	).Scan(&st.Embedded); err != nil {
+		return nil, fmt.Errorf("count embeddings: %w", err)
+	}
+
+	st.Stale = st.Indexed < st.Embedded || st.Embedded > builtCount*annMaxGrowthRatio
+	return st, nil
+}
+
+// Rebuild trains fresh centroids on the stored embeddings and reassigns every
+// row. Only embeddings of the most common dimension are indexed.
+func (x *ANNIndex) Rebuild() (*ANNStatus, error) {
+	rows, err := x.db.Query(`SELECT id, embedding, embedding_dim FROM archival WHERE embedding IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("load embeddings: %w", err)
+	}
+	byDim := map[int][]int64{}
+	vecs := map[int64][]float32{}
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		var dim int
+		if err := rows.Scan(&id, &blob, &dim); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		v := embeddings.Decode(blob, dim)
+		if len(v) == 0 {
+			continue
+		}
+		byDim[len(v)] = append(byDim[len(v)], id)
+		vecs[id] = v
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dim := 0
+	for d, ids := range byDim {
+		if len(ids) > len(byDim[dim]) || (len(ids) == len(byDim[dim]) && d > dim) {
+			dim = d
+		}
+	}
+	ids := byDim[dim]
+
+	err = withTx(x.db, func(tx DBTX) error {
+		for _, stmt := range []string{
+			`DELETE FROM ann_assignments`,
+			`DELETE FROM ann_centroids`,
+			`DELETE FROM ann_meta`,
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("clear ann index: %w", err)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		lists := int(math.Sqrt(float64(len(ids))))
+		if lists < 1 {
+			lists = 1
+		}
+		if lists > annMaxLists {
+			lists = annMaxLists
+		}
+
+		// Train on an evenly strided sample; assigning the full set is cheap
+		// by comparison.
+		sampleSize := lists * annTrainPerList
+		if sampleSize > len(ids) {
+			sampleSize = len(ids)
+		}
+		sample := make([][]float32, sampleSize)
+		for i := range sample {
+			sample[i] = vecs[ids[i*len(ids)/sampleSize]]
+		}
+		centroids := embeddings.KMeans(sample, lists, annIterations)
+
+		for i, c := range centroids {
+			if _, err := tx.Exec(
+				`INSERT INTO ann_centroids (id, vector) VALUES (?, ?)`,
+				i+1, embeddings.SerializeEmbedding(c),
+			); err != nil {
+				return fmt.Errorf("insert centroid: %w", err)
+			}
+		}
+		for _, id := range ids {
+			c := embeddings.Nearest(centroids, vecs[id])
+			if _, err := tx.Exec(
+				`INSERT INTO ann_assignments (archival_id, centroid_id) VALUES (?, ?)`,
+				id, c+1,
+			); err != nil {
+				return fmt.Errorf("assign %d: %w", id, err)
+			}
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO ann_meta (id, dim, built_count) VALUES (1, ?, ?)`,
+			dim, len(ids),
+		); err != nil {
+			return fmt.Errorf("write ann meta: %w", err)
+		}
+		return nil
+	})
+	x.loaded = nil
+	if err != nil {
+		return nil, err
+	}
+	return x.Status()
+}
+
+// Assign adds an archival row to the list of its nearest centroid. It is a
+// no-op when no index has been built or the dimension differs; such rows
+// leave the index stale until the next rebuild.
+func (x *ANNIndex) Assign(archivalID int64, vec []float32) error {
+	centroids, err := x.centroids()
+	if err != nil || len(centroids) == 0 {
+		return err
+	}
+	c := embeddings.Nearest(centroids, vec)
+	if c < 0 {
+		return nil
+	}
+	_, err = x.db.Exec(
+		`INSERT OR REPLACE INTO ann_assignments (archival_id, centroid_id) VALUES (?, ?)`,
+		archivalID, c+1,
+	)
+	if err != nil {
+		return fmt.Errorf("ann assign %d: %w", archivalID, err)
+	}
+	return nil
+}
+
+// Probe returns the centroid IDs whose lists should be scanned for vec. The
+// boolean is false when the index is missing, stale, or built for a
+// different dimension, in which case callers should fall back to a full scan.
+func (x *ANNIndex) Probe(vec []float32) ([]int64, bool, error) {
+	st, err := x.Status()
+	if err != nil {
+		return nil, false, err
+	}
+	if !st.Built || st.Stale || st.Dim != len(vec) {
+		return nil, false, nil
+	}
+
+	centroids, err := x.centroids()
+	if err != nil {
+		return nil, false, err
+	}
+	probes := len(centroids) / 10
+	if probes < annMinProbes {
+		probes = annMinProbes
+	}
+
+	q := embeddings.Normalize(vec)
+	type scored struct {
+		id  int64
+		dot float32
+	}
+	ranked := make([]scored, len(centroids))
+	for i, c := range centroids {
+		var dot float32
+		for j := range c {
+			dot += c[j] * q[j]
+		}
+		ranked[i] = scored{int64(i + 1), dot}
+	}
+	// Partial selection sort — probes is small relative to the list count.
+	var ids []int64
+	for n := 0; n < probes && n < len(ranked); n++ {
+		best := n
+		for i := n + 1; i < len(ranked); i++ {
+			if ranked[i].dot > ranked[best].dot {
+				best = i
+			}
+		}
+		ranked[n], ranked[best] = ranked[best], ranked[n]
+		ids = append(ids, ranked[n].id)
+	}
+	return ids, true, nil
+}
+
+// centroids returns the centroid vectors ordered by ID, so index i holds
+// centroid i+1, loading them on first use.
+func (x *ANNIndex) centroids() ([][]float32, error) {
+	if x.loaded != nil {
+		return x.loaded, nil
+	}
+	rows, err := x.db.Query(`SELECT vector FROM ann_centroids ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("load centroids: %w", err)
+	}
+	defer rows.Close()
+
+	var centroids [][]float32
+	for rows.Next() {
+		var blob []byte
+		if err := rows.Scan(&blob); err != nil {
+			return nil, err
+		}
+		centroids = append(centroids, embeddings.DeserializeEmbedding(blob))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	x.loaded = centroids
+	return centroids, nil
+}
+
+// placeholders returns "?, ?, ..." for n query parameters.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
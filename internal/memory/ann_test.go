@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stukennedy/botmem/internal/embeddings"
+)
+
+func randomVec(r *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = r.Float32()*2 - 1
+	}
+	return v
+}
+
+func TestANNStatus_NotBuilt(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("fact", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+
+	st, err := NewANNIndex(store.db).Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if st.Built {
+		t.Error("expected index not built")
+	}
+	if st.Embedded != 1 {
+		t.Errorf("expected 1 embedded row, got %d", st.Embedded)
+	}
+}
+
+func TestANNRebuild_IndexesAllRows(t *testing.T) {
+	store := testArchivalStore(t)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		store.Add("fact", nil, embeddings.SerializeEmbedding(randomVec(r, 8)))
+	}
+	store.Add("other dimension", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+
+	st, err := NewANNIndex(store.db).Rebuild()
+	if err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if !st.Built || st.Stale {
+		t.Fatalf("expected fresh index, got %+v", st)
+	}
+	if st.Dim != 8 {
+		t.Errorf("expected majority dimension 8, got %d", st.Dim)
+	}
+	if st.Indexed != 100 {
+		t.Errorf("expected 100 indexed rows, got %d", st.Indexed)
+	}
+	if st.Lists != 10 {
+		t.Errorf("expected sqrt(100)=10 lists, got %d", st.Lists)
+	}
+}
+
+func TestANNSearch_FindsExactMatch(t *testing.T) {
+	store := testArchivalStore(t)
+	r := rand.New(rand.NewSource(2))
+	var target []float32
+	for i := 0; i < 400; i++ {
+		v := randomVec(r, 16)
+		content := "noise"
+		if i == 123 {
+			target, content = v, "target"
+		}
+		store.Add(content, nil, embeddings.SerializeEmbedding(v))
+	}
+	if _, err := NewANNIndex(store.db).Rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+
+	if _, ok, _ := NewANNIndex(store.db).Probe(target); !ok {
+		t.Fatal("expected index to be used")
+	}
+	results, err := store.SearchSemantic(target, 1)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "target" {
+		t.Errorf("expected exact match via index, got %+v", results)
+	}
+}
+
+func TestANNIndex_AddAndDeleteKeepIndexFresh(t *testing.T) {
+	store := testArchivalStore(t)
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 50; i++ {
+		store.Add("fact", nil, embeddings.SerializeEmbedding(randomVec(r, 8)))
+	}
+	index := NewANNIndex(store.db)
+	if _, err := index.Rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+
+	e, err := store.Add("new fact", nil, embeddings.SerializeEmbedding(randomVec(r, 8)))
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	st, _ := index.Status()
+	if st.Indexed != 51 || st.Stale {
+		t.Errorf("expected new row indexed on add, got %+v", st)
+	}
+
+	if err := store.Delete(e.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	st, _ = index.Status()
+	if st.Indexed != 50 || st.Stale {
+		t.Errorf("expected row removed from index on delete, got %+v", st)
+	}
+}
+
+func TestANNIndex_StaleFallsBackToBruteForce(t *testing.T) {
+	store := testArchivalStore(t)
+	r := rand.New(rand.NewSource(4))
+	for i := 0; i < 10; i++ {
+		store.Add("fact", nil, embeddings.SerializeEmbedding(randomVec(r, 8)))
+	}
+	index := NewANNIndex(store.db)
+	if _, err := index.Rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+
+	// Growing the archive well past the trained size marks the index stale.
+	for i := 0; i < 20; i++ {
+		store.Add("fact", nil, embeddings.SerializeEmbedding(randomVec(r, 8)))
+	}
+	st, _ := index.Status()
+	if !st.Stale {
+		t.Fatalf("expected stale index after growth, got %+v", st)
+	}
+
+	q := randomVec(r, 8)
+	if _, ok, _ := index.Probe(q); ok {
+		t.Error("expected stale index not to be probed")
+	}
+	results, err := store.SearchSemantic(q, 30)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 30 {
+		t.Errorf("expected brute force to score all 30 rows, got %d", len(results))
+	}
+}
+
+func TestANNIndex_RebuildReloadsCentroids(t *testing.T) {
+	store := testArchivalStore(t)
+	r := rand.New(rand.NewSource(5))
+	var ids []int64
+	for i := 0; i < 100; i++ {
+		e, _ := store.Add("fact", nil, embeddings.SerializeEmbedding(randomVec(r, 8)))
+		ids = append(ids, e.ID)
+	}
+	index := NewANNIndex(store.db)
+	if _, err := index.Rebuild(); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if err := index.Assign(ids[0], randomVec(r, 8)); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+
+	// Shrink the archive so the next build has far fewer centroids; an
+	// index still holding the old ones would assign rows to missing lists.
+	if err := store.Delete(ids[4:]...); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	st, err := index.Rebuild()
+	if err != nil || st.Lists != 2 {
+		t.Fatalf("rebuild: %+v (%v)", st, err)
+	}
+	for _, id := range ids[:4] {
+		if err := index.Assign(id, randomVec(r, 8)); err != nil {
+			t.Fatalf("assign after rebuild: %v", err)
+		}
+	}
+	var lists int
+	store.db.QueryRow(`SELECT COUNT(DISTINCT centroid_id) FROM ann_assignments WHERE centroid_id > 2`).Scan(&lists)
+	if lists != 0 {
+		t.Errorf("expected rows assigned only to the rebuilt lists, got %d stale lists", lists)
+	}
+}
@@ -3,102 +3,500 @@ package memory
 import (
 	"database/sql"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/stukennedy/botmem/internal/embeddings"
 )
 
 type ArchivalEntry struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Tags      string    `json:"tags"`
-	Embedding []byte    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             int64     `json:"id"`
+	Content        string    `json:"content"`
+	Tags           string    `json:"tags"`
+	Embedding      []byte    `json:"-"`
+	EmbeddingModel string    `json:"embedding_model,omitempty"` // empty if unknown or not embedded
+	EmbeddingDim   int       `json:"embedding_dim,omitempty"`
+	Source         string    `json:"source,omitempty"`        // file, URL or conversation the entry came from, if known
+	SourceOffset   int       `json:"source_offset,omitempty"` // byte offset of the entry in Source
+	Metadata       Metadata  `json:"metadata,omitempty"`      // author and any other caller-defined fields
+	Importance     float64   `json:"importance"`              // 0 (trivial) to 1 (never forget)
+	RunID          *int64    `json:"run_id,omitempty"`        // ingest run that created the entry
+	CreatedAt      time.Time `json:"created_at"`
+	Access
+}
+
+// archivalColumns are the columns read by scanArchival, qualified with the
+// "a" alias that every archival query uses.
+const archivalColumns = `a.id, a.content, a.tags, COALESCE(a.embedding_model, ''), COALESCE(a.embedding_dim, 0),
+	COALESCE(a.source, ''), COALESCE(a.source_offset, 0), a.metadata,
+	a.importance, a.access_count, a.last_accessed_at, a.archived_at, a.run_id, a.created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanArchival scans archivalColumns followed by any extra columns.
+func scanArchival(row rowScanner, extra ...any) (*ArchivalEntry, error) {
+	e := &ArchivalEntry{}
+	dest := append([]any{&e.ID, &e.Content, &e.Tags, &e.EmbeddingModel, &e.EmbeddingDim,
+		&e.Source, &e.SourceOffset, &e.Metadata,
+		&e.Importance, &e.AccessCount, &e.LastAccessedAt, &e.ArchivedAt, &e.RunID, &e.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SearchResult is an archival entry paired with its retrieval score.
+// Hybrid searches also report each component ranking; a zero rank means the
+// entry did not appear in that ranking. Entries found by full-text search
+// carry a Snippet: the best-matching excerpt of their content, with the
+// matched terms highlighted.
+type SearchResult struct {
+	*ArchivalEntry
+	Snippet       string  `json:"snippet,omitempty"`
+	Score         float64 `json:"score"`
+	LexicalScore  float64 `json:"lexical_score,omitempty"`
+	LexicalRank   int     `json:"lexical_rank,omitempty"`
+	SemanticScore float64 `json:"semantic_score,omitempty"`
+	SemanticRank  int     `json:"semantic_rank,omitempty"`
+	Recency       float64 `json:"recency,omitempty"` // age factor applied to Score, with recency weighting on
+}
+
+// ColumnWeights weight matches in each FTS5 column when computing BM25
+// scores. Zero weights default to 1.
+type ColumnWeights struct {
+	Content float64
+	Tags    float64
+}
+
+// Highlight marks the matched terms in snippets, and SnippetTokens is the
+// longest excerpt, in tokens, that a snippet shows.
+const (
+	HighlightOpen  = "**"
+	HighlightClose = "**"
+	SnippetTokens  = 24
+)
+
+// HybridWeights tunes reciprocal rank fusion in SearchHybrid.
+type HybridWeights struct {
+	Lexical  float64 // weight of the FTS5 ranking
+	Semantic float64 // weight of the embedding ranking
+	K        float64 // RRF damping constant, default 60
+}
+
+// AccessSimilarity is the cosine similarity a semantic or hybrid result
+// without a keyword match needs before it counts as an access. Similarity
+// search returns the nearest embeddings however far away they are, and
+// those shouldn't keep unrelated memories from being forgotten.
+const AccessSimilarity = 0.5
+
+// Recency weights search scores by age so that recent entries outrank
+// equally relevant old ones. Each score is scaled by
+// (1-Weight) + Weight·0.5^(age/HalfLife): with Weight 0.3 and a 30-day
+// half-life, a month-old entry keeps 85% of its score and a year-old one
+// about 70%.
+type Recency struct {
+	Weight   float64       // 0 turns recency weighting off, 1 applies the full decay
+	HalfLife time.Duration // age at which the decay reaches one half; default 30 days
+}
+
+// factor is the score multiplier for an entry created at created.
+func (r Recency) factor(created, now time.Time) float64 {
+	halfLife := r.HalfLife
+	if halfLife <= 0 {
+		halfLife = 30 * 24 * time.Hour
+	}
+	age := now.Sub(created)
+	if age < 0 {
+		age = 0
+	}
+	decay := math.Pow(0.5, float64(age)/float64(halfLife))
+	return (1 - r.Weight) + r.Weight*decay
+}
+
+// apply rescales results by age, re-sorts them and trims them to limit.
+// Negative scores (dissimilar embeddings) are divided rather than
+// multiplied, so age never lifts a result.
+func (r Recency) apply(results []*SearchResult, limit int) []*SearchResult {
+	if r.Weight > 0 {
+		now := time.Now()
+		for _, res := range results {
+			res.Recency = r.factor(res.CreatedAt, now)
+			if res.Score >= 0 {
+				res.Score *= res.Recency
+			} else {
+				res.Score /= res.Recency
+			}
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// candidates is how deep to look in a ranking so that entries just below
+// limit can still surface after re-ranking.
+func candidates(limit int) int {
+	return max(limit*5, 50)
 }
 
 type ArchivalStore struct {
-	db *sql.DB
+	db      DBTX
+	model   string                  // embedding model ID; see WithEmbeddingModel
+	quant   embeddings.Quantization // storage encoding for new embeddings
+	filter  Filter                  // restricts lists and searches; see WithFilter
+	recency Recency                 // re-ranks search results; see WithRecency
+	columns ColumnWeights           // BM25 column weights; see WithColumnWeights
+	peek    bool                    // searches don't count as accesses; see Untracked
+	ann     *ANNIndex               // kept for a transaction's writes; see index
+}
+
+// Filter restricts which entries an archival store lists and searches. The
+// zero Filter matches every entry.
+type Filter struct {
+	Tags  TagFilter
+	Since time.Time   // only entries created at or after Since, if set
+	Until time.Time   // only entries created before Until, if set
+	Where []MetaMatch // only entries whose metadata matches every condition
+
+	// Archived selects the entries 'botmem forget' archived instead of the
+	// live ones.
+	Archived bool
 }
 
-func NewArchivalStore(db *sql.DB) *ArchivalStore {
+// clause returns a condition on archival alias "a" implementing the filter.
+func (f Filter) clause() (string, []any) {
+	conds := []string{`a.archived_at IS NULL`}
+	if f.Archived {
+		conds[0] = `a.archived_at IS NOT NULL`
+	}
+	var args []any
+	if cond, tagArgs := f.Tags.clause(); cond != "" {
+		conds = append(conds, cond)
+		args = append(args, tagArgs...)
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, `a.created_at >= ?`)
+		args = append(args, sqlTime(f.Since))
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, `a.created_at < ?`)
+		args = append(args, sqlTime(f.Until))
+	}
+	for _, m := range f.Where {
+		cond, metaArgs := m.clause()
+		conds = append(conds, cond)
+		args = append(args, metaArgs...)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// sqlTime formats t like SQLite's CURRENT_TIMESTAMP, so it compares
+// correctly with stored creation times.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func NewArchivalStore(db DBTX) *ArchivalStore {
 	return &ArchivalStore{db: db}
 }
 
+// WithEmbeddingModel returns a store that records model as the producer of
+// the embeddings it writes, and whose semantic searches only compare against
+// embeddings from that model (or of unknown origin, from before models were
+// recorded).
+func (s *ArchivalStore) WithEmbeddingModel(model string) *ArchivalStore {
+	c := *s
+	c.model = model
+	return &c
+}
+
+// WithQuantization returns a store that writes new embeddings in encoding q.
+// Entries already stored keep their encoding until converted with
+// Requantize; reads handle every encoding.
+func (s *ArchivalStore) WithQuantization(q embeddings.Quantization) *ArchivalStore {
+	c := *s
+	c.quant = q
+	return &c
+}
+
+// WithFilter returns a store whose List and search methods only return
+// entries matching f.
+func (s *ArchivalStore) WithFilter(f Filter) *ArchivalStore {
+	c := *s
+	c.filter = f
+	return &c
+}
+
+// WithRecency returns a store whose searches weight results by age as r
+// describes.
+func (s *ArchivalStore) WithRecency(r Recency) *ArchivalStore {
+	c := *s
+	c.recency = r
+	return &c
+}
+
+// WithColumnWeights returns a store whose full-text searches weight matches
+// in content and tags as w describes.
+func (s *ArchivalStore) WithColumnWeights(w ColumnWeights) *ArchivalStore {
+	c := *s
+	c.columns = w
+	return &c
+}
+
+// Untracked returns a store whose searches don't count as accesses, for
+// reads made on the user's behalf rather than by them, such as gathering
+// context for ingest.
+func (s *ArchivalStore) Untracked() *ArchivalStore {
+	c := *s
+	c.peek = true
+	return &c
+}
+
+// encodeEmbedding converts a float32 embedding, as produced by
+// embeddings.SerializeEmbedding, to the store's encoding and returns it with
+// the model and dimension columns to store alongside. All three are NULL
+// when there is no embedding.
+func (s *ArchivalStore) encodeEmbedding(embedding []byte) (blob []byte, model, dim any) {
+	if embedding == nil {
+		return nil, nil, nil
+	}
+	if s.model != "" {
+		model = s.model
+	}
+	vec := embeddings.DeserializeEmbedding(embedding)
+	return embeddings.Encode(vec, s.quant), model, len(vec)
+}
+
 func (s *ArchivalStore) Add(content string, tags []string, embedding []byte) (*ArchivalEntry, error) {
-	tagStr := strings.Join(tags, ",")
-	res, err := s.db.Exec(
-		`INSERT INTO archival (content, tags, embedding) VALUES (?, ?, ?)`,
-		content, tagStr, embedding,
-	)
+	return s.Insert(NewArchival{Content: content, Tags: tags, Embedding: embedding})
+}
+
+// NewArchival is an entry to store with Insert.
+type NewArchival struct {
+	Content      string
+	Tags         []string
+	Source       string // file path, URL, conversation ID...
+	SourceOffset int    // where in Source the content starts, for files
+	Metadata     Metadata
+	Importance   *float64 // 0-1, or nil for DefaultImportance
+	Embedding    []byte   // float32 embedding of Content, or nil
+}
+
+// Insert stores a new entry with its source and metadata.
+func (s *ArchivalStore) Insert(n NewArchival) (*ArchivalEntry, error) {
+	importance := DefaultImportance
+	if n.Importance != nil {
+		importance = *n.Importance
+	}
+	if err := checkImportance(importance); err != nil {
+		return nil, err
+	}
+	blob, model, dim := s.encodeEmbedding(n.Embedding)
+	var offset any
+	if n.Source != "" {
+		offset = n.SourceOffset
+	}
+	var id int64
+	err := withTx(s.db, func(tx DBTX) error {
+		refs, err := ensureTags(tx, normalizeTags(n.Tags))
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(
+			`INSERT INTO archival (content, tags, embedding, embedding_model, embedding_dim,
+				source, source_offset, metadata, importance)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)`,
+			n.Content, tagNames(refs), blob, model, dim, n.Source, offset, n.Metadata, importance,
+		)
+		if err != nil {
+			return fmt.Errorf("add archival: %w", err)
+		}
+		id, _ = res.LastInsertId()
+		if err := linkTags(tx, id, refs); err != nil {
+			return err
+		}
+		if n.Embedding != nil {
+			return s.index(tx).Assign(id, embeddings.DeserializeEmbedding(n.Embedding))
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("add archival: %w", err)
+		return nil, err
 	}
-	id, _ := res.LastInsertId()
 	return s.GetByID(id)
 }
 
+// Vector decodes the entry's stored embedding, whatever its encoding. It is
+// nil when the entry has no embedding or it wasn't loaded.
+func (e *ArchivalEntry) Vector() []float32 {
+	if e.Embedding == nil {
+		return nil
+	}
+	return embeddings.Decode(e.Embedding, e.EmbeddingDim)
+}
+
+// splitTags parses the stored comma-joined tag string.
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
 func (s *ArchivalStore) GetByID(id int64) (*ArchivalEntry, error) {
-	e := &ArchivalEntry{}
-	err := s.db.QueryRow(
-		`SELECT id, content, tags, embedding, created_at FROM archival WHERE id = ?`, id,
-	).Scan(&e.ID, &e.Content, &e.Tags, &e.Embedding, &e.CreatedAt)
+	var emb []byte
+	e, err := scanArchival(s.db.QueryRow(
+		`SELECT `+archivalColumns+`, a.embedding FROM archival a WHERE a.id = ?`, id,
+	), &emb)
 	if err != nil {
 		return nil, fmt.Errorf("get archival %d: %w", id, err)
 	}
+	e.Embedding = emb
 	return e, nil
 }
 
-func (s *ArchivalStore) Search(query string, limit int) ([]*ArchivalEntry, error) {
+// Search runs an FTS5 query and returns the best matches by BM25 score, with
+// snippets.
+func (s *ArchivalStore) Search(query string, limit int) ([]*SearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	depth := limit
+	if s.recency.Weight > 0 {
+		depth = candidates(limit)
+	}
+	results, err := s.searchFTS(query, depth)
+	if err != nil {
+		return nil, err
+	}
+	return s.accessed(s.recency.apply(results, limit))
+}
+
+// searchFTS runs an FTS5 query and keeps the BM25 score, negated so that
+// higher is better. An empty query matches nothing.
+func (s *ArchivalStore) searchFTS(query string, limit int) ([]*SearchResult, error) {
 	if limit <= 0 {
 		limit = 10
 	}
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	w := s.columns
+	if w.Content <= 0 {
+		w.Content = 1
+	}
+	if w.Tags <= 0 {
+		w.Tags = 1
+	}
+	cond, condArgs := s.filter.clause()
+	args := []any{w.Content, w.Tags, HighlightOpen, HighlightClose, SnippetTokens, query}
+	args = append(append(args, condArgs...), limit)
 	rows, err := s.db.Query(
-		`SELECT a.id, a.content, a.tags, a.created_at
+		`SELECT `+archivalColumns+`, bm25(archival_fts, ?, ?) AS score,
+			snippet(archival_fts, 0, ?, ?, '…', ?)
 		FROM archival_fts f
 		JOIN archival a ON a.id = f.rowid
-		WHERE archival_fts MATCH ?
-		ORDER BY rank
+		WHERE archival_fts MATCH ?`+and(cond)+`
+		ORDER BY score
 		LIMIT ?`,
-		query, limit,
+		args...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("search archival: %w", err)
+		return nil, fmt.Errorf("search archival: %w", ftsError(query, err))
 	}
 	defer rows.Close()
 
-	var entries []*ArchivalEntry
+	var results []*SearchResult
 	for rows.Next() {
-		e := &ArchivalEntry{}
-		if err := rows.Scan(&e.ID, &e.Content, &e.Tags, &e.CreatedAt); err != nil {
+		var rank float64
+		var snippet string
+		e, err := scanArchival(rows, &rank, &snippet)
+		if err != nil {
 			return nil, err
 		}
-		entries = append(entries, e)
+		results = append(results, &SearchResult{ArchivalEntry: e, Snippet: snippet, Score: -rank})
 	}
-	return entries, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search archival: %w", ftsError(query, err))
+	}
+	return results, nil
 }
 
+// List returns the most recent entries matching the store's filter, only
+// those tagged tag (or its descendants) if it isn't empty.
 func (s *ArchivalStore) List(tag string, limit int) ([]*ArchivalEntry, error) {
 	if limit <= 0 {
 		limit = 50
 	}
-	query := `SELECT id, content, tags, created_at FROM archival`
-	var args []any
+	f := s.filter
 	if tag != "" {
-		query += ` WHERE tags LIKE ?`
-		args = append(args, "%"+tag+"%")
+		f.Tags.All = append([]string{tag}, f.Tags.All...)
+	}
+	query := `SELECT ` + archivalColumns + ` FROM archival a`
+	cond, args := f.clause()
+	if cond != "" {
+		query += ` WHERE ` + cond
 	}
-	query += ` ORDER BY created_at DESC LIMIT ?`
+	query += ` ORDER BY a.created_at DESC LIMIT ?`
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query, args...)
+	entries, err := s.queryArchival(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list archival: %w", err)
 	}
+	return entries, nil
+}
+
+// Select returns every entry matching the store's filter and, unless query
+// is empty, the FTS5 query, newest first.
+func (s *ArchivalStore) Select(query string) ([]*ArchivalEntry, error) {
+	var conds []string
+	var args []any
+	if query != "" {
+		conds = append(conds, `a.id IN (SELECT rowid FROM archival_fts WHERE archival_fts MATCH ?)`)
+		args = append(args, query)
+	}
+	if cond, filterArgs := s.filter.clause(); cond != "" {
+		conds = append(conds, cond)
+		args = append(args, filterArgs...)
+	}
+	q := `SELECT ` + archivalColumns + ` FROM archival a`
+	if len(conds) > 0 {
+		q += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	entries, err := s.queryArchival(q+` ORDER BY a.created_at DESC, a.id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select archival: %w", ftsError(query, err))
+	}
+	return entries, nil
+}
+
+// and prefixes a non-empty SQL condition with AND.
+func and(cond string) string {
+	if cond == "" {
+		return ""
+	}
+	return ` AND ` + cond
+}
+
+// queryArchival runs a query that selects archivalColumns and scans every row.
+func (s *ArchivalStore) queryArchival(query string, args ...any) ([]*ArchivalEntry, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var entries []*ArchivalEntry
 	for rows.Next() {
-		e := &ArchivalEntry{}
-		if err := rows.Scan(&e.ID, &e.Content, &e.Tags, &e.CreatedAt); err != nil {
+		e, err := scanArchival(rows)
+		if err != nil {
 			return nil, err
 		}
 		entries = append(entries, e)
@@ -106,16 +504,325 @@ func (s *ArchivalStore) List(tag string, limit int) ([]*ArchivalEntry, error) {
 	return entries, rows.Err()
 }
 
-func (s *ArchivalStore) Delete(id int64) error {
-	_, err := s.db.Exec(`DELETE FROM archival WHERE id = ?`, id)
-	return err
+// ArchivalPatch is a change to an archival entry. Nil fields are left as
+// they are.
+type ArchivalPatch struct {
+	Content    *string
+	Tags       []string // replaces every tag; an empty, non-nil slice removes them all
+	Source     *string  // replaces the source; "" removes it
+	Metadata   Metadata // replaces the metadata; an empty, non-nil map removes it
+	Importance *float64
+
+	// Embedding is the float32 embedding of the new content. When the
+	// content changes it always replaces the stored embedding, so a nil
+	// Embedding leaves the entry unembedded until 'embeddings reembed'.
+	Embedding []byte
 }
 
-// SearchWithEmbedding retrieves all entries with embeddings for cosine similarity comparison.
-// The actual similarity computation happens in Go.
+// Update applies p to an entry, keeping its ID, provenance and creation
+// time. The FTS row is refreshed by trigger and the ANN assignment follows
+// the new embedding.
+func (s *ArchivalStore) Update(id int64, p ArchivalPatch) (*ArchivalEntry, error) {
+	err := withTx(s.db, func(tx DBTX) error {
+		var content string
+		if err := tx.QueryRow(`SELECT content FROM archival WHERE id = ?`, id).Scan(&content); err != nil {
+			return fmt.Errorf("update archival %d: %w", id, err)
+		}
+
+		if p.Tags != nil {
+			refs, err := ensureTags(tx, normalizeTags(p.Tags))
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE archival SET tags = ? WHERE id = ?`, tagNames(refs), id); err != nil {
+				return fmt.Errorf("update archival %d: %w", id, err)
+			}
+			if err := linkTags(tx, id, refs); err != nil {
+				return err
+			}
+			if err := pruneTags(tx); err != nil {
+				return err
+			}
+		}
+
+		if p.Source != nil {
+			// The offset belonged to the old source.
+			if _, err := tx.Exec(
+				`UPDATE archival SET source = NULLIF(?, ''), source_offset = NULL WHERE id = ?`, *p.Source, id,
+			); err != nil {
+				return fmt.Errorf("update archival %d: %w", id, err)
+			}
+		}
+		if p.Metadata != nil {
+			if _, err := tx.Exec(`UPDATE archival SET metadata = ? WHERE id = ?`, p.Metadata, id); err != nil {
+				return fmt.Errorf("update archival %d: %w", id, err)
+			}
+		}
+		if p.Importance != nil {
+			if err := checkImportance(*p.Importance); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE archival SET importance = ? WHERE id = ?`, *p.Importance, id); err != nil {
+				return fmt.Errorf("update archival %d: %w", id, err)
+			}
+		}
+
+		changed := p.Content != nil && *p.Content != content
+		if changed {
+			if _, err := tx.Exec(`UPDATE archival SET content = ? WHERE id = ?`, *p.Content, id); err != nil {
+				return fmt.Errorf("update archival %d: %w", id, err)
+			}
+		}
+		if changed || p.Embedding != nil {
+			return s.withDB(tx).SetEmbedding(id, p.Embedding)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(id)
+}
+
+// withDB returns a copy of the store running its queries on db.
+func (s *ArchivalStore) withDB(db DBTX) *ArchivalStore {
+	c := *s
+	c.db, c.ann = db, nil
+	return &c
+}
+
+// SetEmbedding replaces only an entry's embedding, recording the store's
+// model as its producer.
+func (s *ArchivalStore) SetEmbedding(id int64, embedding []byte) error {
+	blob, model, dim := s.encodeEmbedding(embedding)
+	res, err := s.db.Exec(
+		`UPDATE archival SET embedding = ?, embedding_model = ?, embedding_dim = ? WHERE id = ?`,
+		blob, model, dim, id,
+	)
+	if err != nil {
+		return fmt.Errorf("set embedding %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("set embedding %d: %w", id, sql.ErrNoRows)
+	}
+	return s.reassign(id, embeddings.DeserializeEmbedding(embedding))
+}
+
+// StaleEmbeddings returns up to limit entries, in ID order after afterID,
+// that have no embedding or one not produced by the store's model. Legacy
+// embeddings of unknown origin count as stale.
+func (s *ArchivalStore) StaleEmbeddings(afterID int64, limit int) ([]*ArchivalEntry, error) {
+	entries, err := s.queryArchival(
+		`SELECT `+archivalColumns+` FROM archival a
+		WHERE a.id > ? AND (a.embedding IS NULL OR a.embedding_model IS NOT ?)
+		ORDER BY a.id LIMIT ?`,
+		afterID, s.model, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("stale embeddings: %w", err)
+	}
+	return entries, nil
+}
+
+// CountStaleEmbeddings counts the entries StaleEmbeddings would return.
+func (s *ArchivalStore) CountStaleEmbeddings() (int, error) {
+	var n int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM archival WHERE embedding IS NULL OR embedding_model IS NOT ?`, s.model,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count stale embeddings: %w", err)
+	}
+	return n, nil
+}
+
+// storedEmbedding returns the columns to write back a snapshot of an
+// entry's embedding exactly as it was stored.
+func storedEmbedding(e *ArchivalEntry) (model, dim any) {
+	if e.Embedding == nil {
+		return nil, nil
+	}
+	if e.EmbeddingModel != "" {
+		model = e.EmbeddingModel
+	}
+	return model, e.EmbeddingDim
+}
+
+// restore re-inserts a deleted entry under its original ID. It is called
+// within a transaction.
+func (s *ArchivalStore) restore(e *ArchivalEntry) error {
+	refs, err := ensureTags(s.db, normalizeTags(splitTags(e.Tags)))
+	if err != nil {
+		return err
+	}
+	model, dim := storedEmbedding(e)
+	_, err = s.db.Exec(
+		`INSERT INTO archival (id, content, tags, embedding, embedding_model, embedding_dim,
+			source, source_offset, metadata, importance, access_count, last_accessed_at, run_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.Content, tagNames(refs), e.Embedding, model, dim,
+		e.Source, sourceOffset(e), e.Metadata, e.Importance, e.AccessCount, e.LastAccessedAt, e.RunID, e.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("restore archival %d: %w", e.ID, err)
+	}
+	if err := linkTags(s.db, e.ID, refs); err != nil {
+		return err
+	}
+	return s.reassign(e.ID, e.Vector())
+}
+
+// sourceOffset is the source_offset column for e: NULL unless it has a
+// source.
+func sourceOffset(e *ArchivalEntry) any {
+	if e.Source == "" {
+		return nil
+	}
+	return e.SourceOffset
+}
+
+// revert puts an existing entry's content, tags and embedding back to a
+// snapshot taken before it was updated. It is called within a transaction.
+func (s *ArchivalStore) revert(e *ArchivalEntry) error {
+	refs, err := ensureTags(s.db, normalizeTags(splitTags(e.Tags)))
+	if err != nil {
+		return err
+	}
+	model, dim := storedEmbedding(e)
+	_, err = s.db.Exec(
+		`UPDATE archival SET content = ?, tags = ?, embedding = ?, embedding_model = ?, embedding_dim = ?,
+			metadata = ?, importance = ?
+		WHERE id = ?`,
+		e.Content, tagNames(refs), e.Embedding, model, dim, e.Metadata, e.Importance, e.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("revert archival %d: %w", e.ID, err)
+	}
+	if err := linkTags(s.db, e.ID, refs); err != nil {
+		return err
+	}
+	if err := pruneTags(s.db); err != nil {
+		return err
+	}
+	return s.reassign(e.ID, e.Vector())
+}
+
+// reassign moves an entry to the ANN list matching its embedding vector, or
+// drops it from the index when it no longer has one.
+func (s *ArchivalStore) reassign(id int64, vec []float32) error {
+	if _, err := s.db.Exec(`DELETE FROM ann_assignments WHERE archival_id = ?`, id); err != nil {
+		return fmt.Errorf("ann unassign %d: %w", id, err)
+	}
+	if len(vec) == 0 {
+		return nil
+	}
+	return s.index(s.db).Assign(id, vec)
+}
+
+// index returns the ANN index to keep up to date as entries change in db.
+// A store working in a transaction reuses one index, so a batch of writes
+// loads the centroids once; nothing else can rebuild them mid-transaction.
+// Other stores get a fresh index each time.
+func (s *ArchivalStore) index(db DBTX) *ANNIndex {
+	if _, ok := s.db.(*sql.DB); ok {
+		return NewANNIndex(db)
+	}
+	if s.ann == nil {
+		s.ann = NewANNIndex(s.db)
+	}
+	return s.ann
+}
+
+// encodedSizeSQL is a SQL expression for the byte length of an archival
+// embedding in encoding q, mirroring embeddings.EncodedSize.
+func encodedSizeSQL(q embeddings.Quantization) string {
+	switch q {
+	case embeddings.Int8:
+		return `(a.embedding_dim + 4)`
+	case embeddings.Binary:
+		return `((a.embedding_dim + 7) / 8)`
+	default:
+		return `(a.embedding_dim * 4)`
+	}
+}
+
+// CountToRequantize counts the embeddings not stored in the store's encoding.
+func (s *ArchivalStore) CountToRequantize() (int, error) {
+	var n int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM archival a
+		WHERE a.embedding IS NOT NULL AND length(a.embedding) != ` + encodedSizeSQL(s.quant),
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count embeddings to convert: %w", err)
+	}
+	return n, nil
+}
+
+// Requantize re-encodes up to limit embeddings, in ID order after afterID,
+// that aren't stored in the store's encoding. It returns how many it
+// converted and the last ID seen, to pass as afterID for the next batch.
+// The model and ANN assignment of each entry are unchanged.
+func (s *ArchivalStore) Requantize(afterID int64, limit int) (int, int64, error) {
+	var entries []*ArchivalEntry
+	rows, err := s.db.Query(
+		`SELECT a.id, a.embedding, a.embedding_dim FROM archival a
+		WHERE a.id > ? AND a.embedding IS NOT NULL AND length(a.embedding) != `+encodedSizeSQL(s.quant)+`
+		ORDER BY a.id LIMIT ?`,
+		afterID, limit,
+	)
+	if err != nil {
+		return 0, afterID, fmt.Errorf("load embeddings to convert: %w", err)
+	}
+	for rows.Next() {
+		e := &ArchivalEntry{}
+		if err := rows.Scan(&e.ID, &e.Embedding, &e.EmbeddingDim); err != nil {
+			rows.Close()
+			return 0, afterID, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, afterID, err
+	}
+
+	err = withTx(s.db, func(tx DBTX) error {
+		for _, e := range entries {
+			if _, err := tx.Exec(
+				`UPDATE archival SET embedding = ? WHERE id = ?`,
+				embeddings.Encode(e.Vector(), s.quant), e.ID,
+			); err != nil {
+				return fmt.Errorf("convert embedding %d: %w", e.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil || len(entries) == 0 {
+		return 0, afterID, err
+	}
+	return len(entries), entries[len(entries)-1].ID, nil
+}
+
+// Delete removes entries in one transaction. Their FTS rows are removed by
+// trigger, and their ANN index assignments and tag links by foreign-key
+// cascade; tags left on no entry are dropped.
+func (s *ArchivalStore) Delete(ids ...int64) error {
+	return withTx(s.db, func(tx DBTX) error {
+		for _, id := range ids {
+			if _, err := tx.Exec(`DELETE FROM archival WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("delete archival %d: %w", id, err)
+			}
+		}
+		return pruneTags(tx)
+	})
+}
+
+// AllWithEmbeddings returns every entry that has a stored embedding, with
+// the embedding itself. The store's filter is not applied.
 func (s *ArchivalStore) AllWithEmbeddings() ([]*ArchivalEntry, error) {
 	rows, err := s.db.Query(
-		`SELECT id, content, tags, embedding, created_at FROM archival WHERE embedding IS NOT NULL`,
+		`SELECT ` + archivalColumns + `, a.embedding FROM archival a WHERE a.embedding IS NOT NULL`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list embeddings: %w", err)
@@ -124,11 +831,169 @@ func (s *ArchivalStore) AllWithEmbeddings() ([]*ArchivalEntry, error) {
 
 	var entries []*ArchivalEntry
 	for rows.Next() {
-		e := &ArchivalEntry{}
-		if err := rows.Scan(&e.ID, &e.Content, &e.Tags, &e.Embedding, &e.CreatedAt); err != nil {
+		var emb []byte
+		e, err := scanArchival(rows, &emb)
+		if err != nil {
 			return nil, err
 		}
+		e.Embedding = emb
 		entries = append(entries, e)
 	}
 	return entries, rows.Err()
 }
+
+// SearchSemantic ranks entries with stored embeddings by cosine similarity to
+// queryVec and returns the top matches. When a usable ANN index exists only
+// the nearest lists are scanned; otherwise every embedding is compared.
+// Entries whose embedding dimension differs from the query, or that were
+// embedded by a model other than the store's, are skipped.
+// With recency weighting, more candidates are scored and re-ranked by age.
+func (s *ArchivalStore) SearchSemantic(queryVec []float32, limit int) ([]*SearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	depth := limit
+	if s.recency.Weight > 0 {
+		depth = candidates(limit)
+	}
+	results, err := s.searchSemantic(queryVec, depth)
+	if err != nil {
+		return nil, err
+	}
+	return s.accessedIfRelevant(s.recency.apply(results, limit))
+}
+
+// searchSemantic ranks by similarity alone; see SearchSemantic. Each
+// result's SemanticScore keeps its similarity through recency weighting.
+func (s *ArchivalStore) searchSemantic(queryVec []float32, limit int) ([]*SearchResult, error) {
+	query := `SELECT a.id, a.embedding, a.embedding_dim FROM archival a WHERE a.embedding IS NOT NULL`
+	var args []any
+	lists, ok, err := NewANNIndex(s.db).Probe(queryVec)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		query = `SELECT a.id, a.embedding, a.embedding_dim
+		FROM ann_assignments x
+		JOIN archival a ON a.id = x.archival_id
+		WHERE x.centroid_id IN (` + placeholders(len(lists)) + `)`
+		for _, id := range lists {
+			args = append(args, id)
+		}
+	}
+	query += ` AND a.embedding_dim = ?`
+	args = append(args, len(queryVec))
+	cond, condArgs := s.filter.clause()
+	query += and(cond)
+	args = append(args, condArgs...)
+	if s.model != "" {
+		query += ` AND (a.embedding_model IS NULL OR a.embedding_model = ?)`
+		args = append(args, s.model)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("semantic search: %w", err)
+	}
+	type hit struct {
+		id    int64
+		score float64
+	}
+	var hits []hit
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		var dim int
+		if err := rows.Scan(&id, &blob, &dim); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		hits = append(hits, hit{id, float64(embeddings.Similarity(queryVec, blob, dim))})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	// Only load full entries for the hits, not every scanned row.
+	results := make([]*SearchResult, 0, len(hits))
+	for _, h := range hits {
+		e, err := s.GetByID(h.id)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &SearchResult{ArchivalEntry: e, Score: h.score, SemanticScore: h.score})
+	}
+	return results, nil
+}
+
+// SearchHybrid runs both the FTS5 and embedding searches and fuses their
+// rankings with weighted reciprocal rank fusion. Each result carries its
+// fused Score along with the lexical and semantic components.
+func (s *ArchivalStore) SearchHybrid(query string, queryVec []float32, limit int, w HybridWeights) ([]*SearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if w.Lexical == 0 && w.Semantic == 0 {
+		w.Lexical, w.Semantic = 1, 1
+	}
+	if w.K <= 0 {
+		w.K = 60
+	}
+	// Look deeper than limit in each ranking so entries that are mid-ranked
+	// in both can still surface after fusion.
+	depth := candidates(limit)
+
+	lexical, err := s.searchFTS(query, depth)
+	if err != nil {
+		return nil, err
+	}
+	semantic, err := s.searchSemantic(queryVec, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	fused := map[int64]*SearchResult{}
+	var results []*SearchResult
+	get := func(e *ArchivalEntry) *SearchResult {
+		r, ok := fused[e.ID]
+		if !ok {
+			r = &SearchResult{ArchivalEntry: e}
+			fused[e.ID] = r
+			results = append(results, r)
+		}
+		return r
+	}
+	for i, l := range lexical {
+		r := get(l.ArchivalEntry)
+		r.LexicalRank = i + 1
+		r.LexicalScore = l.Score
+		r.Snippet = l.Snippet
+		r.Score += w.Lexical / (w.K + float64(i+1))
+	}
+	for i, sm := range semantic {
+		r := get(sm.ArchivalEntry)
+		r.SemanticRank = i + 1
+		r.SemanticScore = sm.Score
+		r.Score += w.Semantic / (w.K + float64(i+1))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return s.accessedIfRelevant(s.recency.apply(results, limit))
+}
+
+// ByRun returns the entries created by an ingest run.
+func (s *ArchivalStore) ByRun(runID int64) ([]*ArchivalEntry, error) {
+	entries, err := s.queryArchival(
+		`SELECT `+archivalColumns+` FROM archival a WHERE a.run_id = ? ORDER BY a.id`, runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("archival for run %d: %w", runID, err)
+	}
+	return entries, nil
+}
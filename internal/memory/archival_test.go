@@ -2,9 +2,13 @@ package memory
 
 import (
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stukennedy/botmem/internal/db"
+	"github.com/stukennedy/botmem/internal/embeddings"
 )
 
 func testArchivalStore(t *testing.T) *ArchivalStore {
@@ -138,6 +142,93 @@ func TestArchivalDelete(t *testing.T) {
 	}
 }
 
+func TestArchivalUpdate(t *testing.T) {
+	store := testArchivalStore(t)
+	e, _ := store.Add("Stu prefers Inside IR35", []string{"work"}, []byte{1, 2, 3, 4})
+
+	content := "Stu prefers Outside IR35"
+	updated, err := store.Update(e.ID, ArchivalPatch{Content: &content, Tags: []string{"work", "contracts"}})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Content != "Stu prefers Outside IR35" || updated.Tags != "work,contracts" || updated.Embedding != nil {
+		t.Errorf("unexpected entry: %+v", updated)
+	}
+	if !updated.CreatedAt.Equal(e.CreatedAt) {
+		t.Error("expected creation time kept")
+	}
+	if results, _ := store.Search("Outside", 10); len(results) != 1 {
+		t.Errorf("expected FTS to see new content, got %d results", len(results))
+	}
+	if results, _ := store.Search("Inside", 10); len(results) != 0 {
+		t.Errorf("expected FTS to drop old content, got %d results", len(results))
+	}
+	if _, err := store.Update(9999, ArchivalPatch{Content: &content}); err == nil {
+		t.Error("expected error updating missing entry")
+	}
+}
+
+func TestArchivalUpdate_Partial(t *testing.T) {
+	store := testArchivalStore(t)
+	emb := embeddings.SerializeEmbedding([]float32{1, 0})
+	e, _ := store.Add("Stu prefers Inside IR35", []string{"work"}, emb)
+
+	retagged, err := store.Update(e.ID, ArchivalPatch{Tags: []string{"work/contracts"}})
+	if err != nil {
+		t.Fatalf("retag: %v", err)
+	}
+	if retagged.Content != e.Content || retagged.Tags != "work/contracts" || retagged.Embedding == nil {
+		t.Errorf("expected only the tags to change, got %+v", retagged)
+	}
+	if tags, _ := NewTagStore(store.db).List(); len(tags) != 1 {
+		t.Errorf("expected the unused tag pruned, got %v", tags)
+	}
+
+	same := e.Content
+	if kept, _ := store.Update(e.ID, ArchivalPatch{Content: &same}); kept.Embedding == nil {
+		t.Error("expected unchanged content to keep its embedding")
+	}
+	edited := "Stu prefers Outside IR35"
+	if got, _ := store.Update(e.ID, ArchivalPatch{Content: &edited}); got.Embedding != nil || got.Tags != "work/contracts" {
+		t.Errorf("expected changed content to drop the stale embedding and keep tags, got %+v", got)
+	}
+
+	cleared, err := store.Update(e.ID, ArchivalPatch{Tags: []string{}})
+	if err != nil || cleared.Tags != "" {
+		t.Errorf("expected tags removed, got %q (%v)", cleared.Tags, err)
+	}
+}
+
+func TestArchivalSelectAndDelete(t *testing.T) {
+	store := testArchivalStore(t)
+	a, _ := store.Add("old contract note", []string{"work"}, nil)
+	b, _ := store.Add("new contract note", []string{"work"}, nil)
+	c, _ := store.Add("gym schedule", []string{"personal"}, nil)
+	store.db.Exec(`UPDATE archival SET created_at = '2025-01-10 12:00:00' WHERE id = ?`, a.ID)
+
+	if got, _ := store.Select("contract"); len(got) != 2 {
+		t.Errorf("expected 2 entries matching the query, got %d", len(got))
+	}
+	old := store.WithFilter(Filter{Until: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)})
+	if got, _ := old.Select(""); len(got) != 1 || got[0].ID != a.ID {
+		t.Errorf("expected only the old entry before February, got %v", got)
+	}
+	recent := store.WithFilter(Filter{Since: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), Tags: TagFilter{All: []string{"work"}}})
+	if got, _ := recent.Select(""); len(got) != 1 || got[0].ID != b.ID {
+		t.Errorf("expected only the recent work entry, got %v", got)
+	}
+
+	if err := store.Delete(a.ID, c.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got, _ := store.Select(""); len(got) != 1 || got[0].ID != b.ID {
+		t.Errorf("expected only %d left, got %v", b.ID, got)
+	}
+	if tags, _ := NewTagStore(store.db).List(); len(tags) != 1 || tags[0].Name != "work" {
+		t.Errorf("expected the personal tag pruned, got %v", tags)
+	}
+}
+
 func TestArchivalAllWithEmbeddings(t *testing.T) {
 	store := testArchivalStore(t)
 	store.Add("no embedding", nil, nil)
@@ -151,3 +242,361 @@ func TestArchivalAllWithEmbeddings(t *testing.T) {
 		t.Errorf("expected 1 entry with embedding, got %d", len(entries))
 	}
 }
+
+func TestArchivalSearchSemantic(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("about cats", nil, embeddings.SerializeEmbedding([]float32{1, 0, 0}))
+	store.Add("about dogs", nil, embeddings.SerializeEmbedding([]float32{0, 1, 0}))
+	store.Add("about kittens", nil, embeddings.SerializeEmbedding([]float32{0.9, 0.1, 0}))
+	store.Add("no embedding", nil, nil)
+
+	results, err := store.SearchSemantic([]float32{1, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Content != "about cats" || results[1].Content != "about kittens" {
+		t.Errorf("unexpected order: %q, %q", results[0].Content, results[1].Content)
+	}
+	if results[0].Score < 0.99 {
+		t.Errorf("expected ~1.0 score for identical vector, got %f", results[0].Score)
+	}
+}
+
+func TestArchivalSearchSemantic_SkipsMismatchedDimensions(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("three dims", nil, embeddings.SerializeEmbedding([]float32{1, 0, 0}))
+	store.Add("two dims", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+
+	results, err := store.SearchSemantic([]float32{1, 0}, 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "two dims" {
+		t.Errorf("expected only the matching-dimension entry, got %d results", len(results))
+	}
+}
+
+func TestArchivalSearchHybrid_FusesBothRankings(t *testing.T) {
+	store := testArchivalStore(t)
+	// Keyword-only match: shares words with the query but points elsewhere.
+	store.Add("contract paperwork for the renewal of the office lease", nil, embeddings.SerializeEmbedding([]float32{0, 1, 0}))
+	// Semantic-only match: no shared words, but close in vector space.
+	store.Add("agreement extension documents", nil, embeddings.SerializeEmbedding([]float32{1, 0, 0}))
+	// Matches both rankings and should win.
+	store.Add("contract renewal", nil, embeddings.SerializeEmbedding([]float32{0.95, 0.05, 0}))
+
+	results, err := store.SearchHybrid("contract renewal", []float32{1, 0, 0}, 10, HybridWeights{})
+	if err != nil {
+		t.Fatalf("hybrid: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 fused results, got %d", len(results))
+	}
+	top := results[0]
+	if top.Content != "contract renewal" {
+		t.Errorf("expected entry matching both rankings first, got %q", top.Content)
+	}
+	if top.LexicalRank == 0 || top.SemanticRank == 0 {
+		t.Errorf("expected both component ranks on top result, got %+v", top)
+	}
+
+	for _, r := range results {
+		if r.Content == "agreement extension documents" && r.LexicalRank != 0 {
+			t.Errorf("semantic-only entry should have no lexical rank, got %d", r.LexicalRank)
+		}
+	}
+}
+
+func TestArchivalSearchHybrid_Weights(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("contract renewal paperwork", nil, embeddings.SerializeEmbedding([]float32{0, 1, 0}))
+	store.Add("agreement extension documents", nil, embeddings.SerializeEmbedding([]float32{1, 0, 0}))
+
+	results, err := store.SearchHybrid("contract", []float32{1, 0, 0}, 10, HybridWeights{Lexical: 0, Semantic: 1})
+	if err != nil {
+		t.Fatalf("hybrid: %v", err)
+	}
+	if results[0].Content != "agreement extension documents" {
+		t.Errorf("expected semantic match first when semantic weight dominates, got %q", results[0].Content)
+	}
+
+	results, err = store.SearchHybrid("contract", []float32{1, 0, 0}, 10, HybridWeights{Lexical: 1, Semantic: 0})
+	if err != nil {
+		t.Fatalf("hybrid: %v", err)
+	}
+	if results[0].Content != "contract renewal paperwork" {
+		t.Errorf("expected keyword match first when lexical weight dominates, got %q", results[0].Content)
+	}
+}
+
+func TestEmbeddingCache_RoundTrip(t *testing.T) {
+	store := testArchivalStore(t)
+	cache := NewEmbeddingCache(store.db)
+
+	if err := cache.Put("m1", map[string][]float32{"h1": {1, 2}, "h2": {3}}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	got, err := cache.Get("m1", []string{"h1", "h2", "h3"})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(got) != 2 || got["h1"][1] != 2 {
+		t.Errorf("unexpected cache contents %v", got)
+	}
+	if other, _ := cache.Get("m2", []string{"h1"}); len(other) != 0 {
+		t.Errorf("expected vectors to be per model, got %v", other)
+	}
+	if n, err := cache.Clear(); err != nil || n != 2 {
+		t.Errorf("expected 2 cleared, got %d (%v)", n, err)
+	}
+}
+
+func TestArchivalSearchSemantic_SkipsOtherModels(t *testing.T) {
+	store := testArchivalStore(t)
+	store.WithEmbeddingModel("model-a").Add("from a", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+	store.WithEmbeddingModel("model-b").Add("from b", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+	store.Add("unknown model", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+
+	results, err := store.WithEmbeddingModel("model-a").SearchSemantic([]float32{1, 0}, 10)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Content)
+	}
+	if len(got) != 2 || got[0] == "from b" || got[1] == "from b" {
+		t.Errorf("expected model-a and unknown-model entries only, got %v", got)
+	}
+	if results[0].EmbeddingDim != 2 {
+		t.Errorf("expected embedding dim 2, got %d", results[0].EmbeddingDim)
+	}
+}
+
+func TestArchivalStaleEmbeddings(t *testing.T) {
+	store := testArchivalStore(t)
+	a := store.WithEmbeddingModel("model-a")
+	a.Add("current", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+	old, _ := store.WithEmbeddingModel("model-old").Add("old model", nil, embeddings.SerializeEmbedding([]float32{1}))
+	store.Add("not embedded", nil, nil)
+
+	if n, err := a.CountStaleEmbeddings(); err != nil || n != 2 {
+		t.Fatalf("expected 2 stale entries, got %d (%v)", n, err)
+	}
+	stale, err := a.StaleEmbeddings(0, 1)
+	if err != nil || len(stale) != 1 || stale[0].ID != old.ID {
+		t.Fatalf("expected first stale entry to be %d, got %v (%v)", old.ID, stale, err)
+	}
+
+	if err := a.SetEmbedding(old.ID, embeddings.SerializeEmbedding([]float32{0, 1})); err != nil {
+		t.Fatalf("set embedding: %v", err)
+	}
+	e, _ := store.GetByID(old.ID)
+	if e.EmbeddingModel != "model-a" || e.EmbeddingDim != 2 || e.Content != "old model" {
+		t.Errorf("unexpected entry after re-embed: %+v", e)
+	}
+	if n, _ := a.CountStaleEmbeddings(); n != 1 {
+		t.Errorf("expected 1 stale entry left, got %d", n)
+	}
+}
+
+func TestArchivalQuantizedStorage(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("float", nil, embeddings.SerializeEmbedding([]float32{0, 1, 0}))
+	int8Store := store.WithQuantization(embeddings.Int8)
+	e, err := int8Store.Add("about cats", nil, embeddings.SerializeEmbedding([]float32{1, 0.1, 0}))
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if len(e.Embedding) != 4+3 || e.EmbeddingDim != 3 {
+		t.Fatalf("expected an int8 blob of 7 bytes, got %d (dim %d)", len(e.Embedding), e.EmbeddingDim)
+	}
+
+	results, err := store.SearchSemantic([]float32{1, 0, 0}, 10)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("expected both encodings to be searchable, got %d (%v)", len(results), err)
+	}
+	if results[0].Content != "about cats" || results[0].Score < 0.99 {
+		t.Errorf("expected the int8 entry first with ~1 similarity, got %q %f", results[0].Content, results[0].Score)
+	}
+
+	if n, _ := int8Store.CountToRequantize(); n != 1 {
+		t.Fatalf("expected 1 float32 embedding to convert, got %d", n)
+	}
+	if n, _, err := int8Store.Requantize(0, 10); err != nil || n != 1 {
+		t.Fatalf("expected 1 converted, got %d (%v)", n, err)
+	}
+	if n, _ := int8Store.CountToRequantize(); n != 0 {
+		t.Errorf("expected nothing left to convert, got %d", n)
+	}
+}
+
+func TestArchivalListTagged_ExactMatch(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("office", []string{"work"}, nil)
+	store.Add("school", []string{"homework"}, nil)
+	store.Add("router", []string{"network", "Work"}, nil)
+	store.Add("garden", []string{"home"}, nil)
+
+	contents := func(f TagFilter) []string {
+		entries, err := store.WithFilter(Filter{Tags: f}).List("", 10)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Content)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	if got := contents(TagFilter{All: []string{"work"}}); strings.Join(got, ",") != "office,router" {
+		t.Errorf("expected exact, case-insensitive match, got %v", got)
+	}
+	if got := contents(TagFilter{All: []string{"work", "network"}}); strings.Join(got, ",") != "router" {
+		t.Errorf("expected AND match, got %v", got)
+	}
+	if got := contents(TagFilter{Any: []string{"homework", "home"}}); strings.Join(got, ",") != "garden,school" {
+		t.Errorf("expected OR match, got %v", got)
+	}
+}
+
+func TestArchivalSearch_FilteredByTag(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("contract renewal", []string{"work/contracts"}, embeddings.SerializeEmbedding([]float32{1, 0}))
+	store.Add("contract for the flat", []string{"personal"}, embeddings.SerializeEmbedding([]float32{1, 0}))
+
+	work := store.WithFilter(Filter{Tags: TagFilter{All: []string{"work"}}})
+	if got, err := work.Search("contract", 10); err != nil || len(got) != 1 || got[0].Content != "contract renewal" {
+		t.Errorf("expected only the work entry from FTS search, got %v (%v)", got, err)
+	}
+	if got, err := work.SearchSemantic([]float32{1, 0}, 10); err != nil || len(got) != 1 {
+		t.Errorf("expected only the work entry from semantic search, got %d (%v)", len(got), err)
+	}
+	if got, err := work.SearchHybrid("contract", []float32{1, 0}, 10, HybridWeights{}); err != nil || len(got) != 1 {
+		t.Errorf("expected only the work entry from hybrid search, got %d (%v)", len(got), err)
+	}
+}
+
+func TestArchivalSearch_Recency(t *testing.T) {
+	store := testArchivalStore(t)
+	old, _ := store.Add("prefers tea in the morning", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+	recent, _ := store.Add("prefers coffee in the morning", nil, embeddings.SerializeEmbedding([]float32{1, 0}))
+	store.db.Exec(`UPDATE archival SET created_at = datetime('now', '-365 days') WHERE id = ?`, old.ID)
+	store.db.Exec(`UPDATE archival SET created_at = datetime('now', '-1 days') WHERE id = ?`, recent.ID)
+
+	weighted := store.WithRecency(Recency{Weight: 0.5, HalfLife: 30 * 24 * time.Hour})
+	if got, _ := weighted.Search("prefers morning", 1); len(got) != 1 || got[0].ID != recent.ID {
+		t.Errorf("expected the recent entry first in FTS search, got %v", got)
+	}
+	results, err := weighted.SearchSemantic([]float32{1, 0}, 2)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("semantic search: %v (%d results)", err, len(results))
+	}
+	if results[0].ID != recent.ID || results[0].Recency <= results[1].Recency {
+		t.Errorf("expected the recent entry first with a larger age factor, got %+v, %+v", results[0], results[1])
+	}
+	if results[1].Recency < 0.5 || results[1].Recency > 0.51 {
+		t.Errorf("expected a year-old entry to keep about half its score, got factor %.3f", results[1].Recency)
+	}
+
+	since := store.WithFilter(Filter{Since: time.Now().Add(-7 * 24 * time.Hour)})
+	if got, _ := since.List("", 10); len(got) != 1 || got[0].ID != recent.ID {
+		t.Errorf("expected only the recent entry in the last week, got %v", got)
+	}
+}
+
+func TestArchivalSearch_SnippetsAndColumnWeights(t *testing.T) {
+	store := testArchivalStore(t)
+	long := "After weighing it up for months Stu decided he prefers outside contracts for every future engagement because of the tax position and the freedom it gives him to pick clients"
+	inContent, _ := store.Add(long, []string{"work"}, nil)
+	inTags, _ := store.Add("rate card for next year", []string{"contracts"}, nil)
+	store.Add("unrelated filler about gardening", nil, nil)
+	store.Add("more filler about cooking", nil, nil)
+
+	results, err := store.Search(PlainQuery("contracts"), 10)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("search: %v (%d results)", err, len(results))
+	}
+	for _, r := range results {
+		if r.Score <= 0 {
+			t.Errorf("expected a positive BM25 score for [%d], got %v", r.ID, r.Score)
+		}
+		if r.ID == inContent.ID {
+			if !strings.Contains(r.Snippet, HighlightOpen+"contracts"+HighlightClose) || len(r.Snippet) >= len(long) {
+				t.Errorf("expected a shortened, highlighted snippet, got %q", r.Snippet)
+			}
+		}
+	}
+
+	first := func(w ColumnWeights) int64 {
+		got, err := store.WithColumnWeights(w).Search(PlainQuery("contracts"), 10)
+		if err != nil || len(got) == 0 {
+			t.Fatalf("weighted search: %v", err)
+		}
+		return got[0].ID
+	}
+	if id := first(ColumnWeights{Content: 10, Tags: 1}); id != inContent.ID {
+		t.Errorf("expected the content match first when content is weighted up, got %d", id)
+	}
+	if id := first(ColumnWeights{Content: 1, Tags: 10}); id != inTags.ID {
+		t.Errorf("expected the tag match first when tags are weighted up, got %d", id)
+	}
+}
+
+func TestArchivalSources_Resync(t *testing.T) {
+	store := testArchivalStore(t)
+	chunks := func(texts ...string) []*SourceChunk {
+		var out []*SourceChunk
+		offset := 0
+		for _, text := range texts {
+			out = append(out, &SourceChunk{Content: text, Offset: offset, Embedding: embeddings.SerializeEmbedding([]float32{1, 0})})
+			offset += len(text) + 2
+		}
+		return out
+	}
+	sync := func(texts ...string) *SourceSync {
+		t.Helper()
+		plan, err := store.PlanSource("/notes/meeting.md", chunks(texts...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.ApplySource(plan, []string{"docs"}); err != nil {
+			t.Fatal(err)
+		}
+		return plan
+	}
+
+	first := sync("# Meeting", "Decided to ship Friday.", "Chris owns the rollout.")
+	if len(first.Add) != 3 || first.Unchanged != 0 {
+		t.Fatalf("expected 3 chunks added, got %+v", first)
+	}
+	before, _ := store.BySource("/notes/meeting.md")
+
+	again := sync("# Meeting", "Decided to ship Friday.", "Chris owns the rollout.")
+	if again.Changed() || again.Unchanged != 3 {
+		t.Errorf("expected an unchanged file to be a no-op, got %+v", again)
+	}
+
+	edited := sync("# Meeting", "Intro added.", "Decided to ship Friday.", "Chris owns the launch.")
+	if len(edited.Add) != 2 || len(edited.Delete) != 1 || edited.Unchanged != 2 || len(edited.Move) != 1 {
+		t.Errorf("expected 2 added, 1 deleted, 2 kept of which 1 moved, got %+v", edited)
+	}
+	after, _ := store.BySource("/notes/meeting.md")
+	if len(after) != 4 {
+		t.Fatalf("expected 4 entries after the edit, got %d", len(after))
+	}
+	if after[0].ID != before[0].ID || after[2].ID != before[1].ID || after[2].SourceOffset != len("# Meeting")+2+len("Intro added.")+2 {
+		t.Errorf("expected unchanged chunks to keep their entries at new offsets, got %+v", after)
+	}
+	if after[1].Tags != "docs" || after[1].Source != "/notes/meeting.md" {
+		t.Errorf("expected added chunks tagged with their source, got %+v", after[1])
+	}
+	if got, _ := store.Search(PlainQuery("rollout"), 10); len(got) != 0 {
+		t.Error("expected the replaced chunk deleted")
+	}
+}
@@ -1,7 +1,6 @@
 package memory
 
 import (
-	"database/sql"
 	"fmt"
 	"time"
 )
@@ -16,10 +15,10 @@ type Block struct {
 }
 
 type BlockStore struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewBlockStore(db *sql.DB) *BlockStore {
+func NewBlockStore(db DBTX) *BlockStore {
 	return &BlockStore{db: db}
 }
 
@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"database/sql"
 	"path/filepath"
 	"testing"
 
@@ -171,3 +172,22 @@ func TestBlockList_OrderedByLabel(t *testing.T) {
 		t.Errorf("not ordered by label: %s, %s, %s", blocks[0].Label, blocks[1].Label, blocks[2].Label)
 	}
 }
+
+func TestBlockStore_InTransaction(t *testing.T) {
+	store := testDB(t)
+	tx, err := store.db.(*sql.DB).Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBlockStore(tx).Create("human", "core", "uncommitted"); err != nil {
+		t.Fatalf("create in tx: %v", err)
+	}
+	if b, err := NewBlockStore(tx).GetByLabel("human"); err != nil || b.Content != "uncommitted" {
+		t.Errorf("expected block visible inside tx, got %v", err)
+	}
+	tx.Rollback()
+
+	if _, err := store.GetByLabel("human"); err == nil {
+		t.Error("expected block discarded after rollback")
+	}
+}
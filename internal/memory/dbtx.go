@@ -0,0 +1,29 @@
+package memory
+
+import "database/sql"
+
+// DBTX is the subset of *sql.DB and *sql.Tx the stores need, so any store can
+// run inside a caller's transaction.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// withTx runs fn in a transaction. If db is already a transaction, fn joins
+// it and the caller stays responsible for committing.
+func withTx(db DBTX, fn func(tx DBTX) error) error {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/stukennedy/botmem/internal/embeddings"
+)
+
+// EmbeddingCache persists embeddings by (model, sha256(text)) so identical
+// text, including repeated search queries, is only embedded once per model.
+// It implements embeddings.Cache.
+type EmbeddingCache struct {
+	db DBTX
+}
+
+func NewEmbeddingCache(db DBTX) *EmbeddingCache {
+	return &EmbeddingCache{db: db}
+}
+
+// embedCacheBatch caps the hashes looked up per query, well under SQLite's
+// bound-parameter limit.
+const embedCacheBatch = 500
+
+func (c *EmbeddingCache) Get(modelID string, hashes []string) (map[string][]float32, error) {
+	found := map[string][]float32{}
+	for start := 0; start < len(hashes); start += embedCacheBatch {
+		end := min(start+embedCacheBatch, len(hashes))
+		args := []any{modelID}
+		for _, h := range hashes[start:end] {
+			args = append(args, h)
+		}
+		rows, err := c.db.Query(
+			`SELECT text_hash, embedding FROM embedding_cache
+			WHERE model = ? AND text_hash IN (`+placeholders(end-start)+`)`,
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("read embedding cache: %w", err)
+		}
+		for rows.Next() {
+			var h string
+			var blob []byte
+			if err := rows.Scan(&h, &blob); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			found[h] = embeddings.DeserializeEmbedding(blob)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+func (c *EmbeddingCache) Put(modelID string, vecs map[string][]float32) error {
+	return withTx(c.db, func(tx DBTX) error {
+		for h, v := range vecs {
+			if _, err := tx.Exec(
+				`INSERT OR REPLACE INTO embedding_cache (model, text_hash, embedding) VALUES (?, ?, ?)`,
+				modelID, h, embeddings.SerializeEmbedding(v),
+			); err != nil {
+				return fmt.Errorf("write embedding cache: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ModelCount is how many cached vectors a model has.
+type ModelCount struct {
+	Model string `json:"model"`
+	Count int    `json:"count"`
+}
+
+// Counts returns the number of cached vectors per model.
+func (c *EmbeddingCache) Counts() ([]ModelCount, error) {
+	rows, err := c.db.Query(`SELECT model, COUNT(*) FROM embedding_cache GROUP BY model ORDER BY model`)
+	if err != nil {
+		return nil, fmt.Errorf("count embedding cache: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []ModelCount
+	for rows.Next() {
+		var mc ModelCount
+		if err := rows.Scan(&mc.Model, &mc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, mc)
+	}
+	return counts, rows.Err()
+}
+
+// Clear empties the cache and returns how many vectors it held.
+func (c *EmbeddingCache) Clear() (int64, error) {
+	res, err := c.db.Exec(`DELETE FROM embedding_cache`)
+	if err != nil {
+		return 0, fmt.Errorf("clear embedding cache: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,320 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultImportance is the importance of memories stored without one.
+// Importance runs from 0 (trivial) to 1 (never forget).
+const DefaultImportance = 0.5
+
+// Access records how often and how recently a memory has been recalled, and
+// when 'botmem forget' archived it, if it did. Archival searches, QueryEntity
+// and the relations a context includes count as an access, except on stores
+// made Untracked. ForgetStore weighs access against importance to decide
+// which memories have decayed far enough to archive or delete; archived
+// memories are kept but hidden from searches, listings and context until
+// restored.
+type Access struct {
+	AccessCount    int        `json:"access_count,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	ArchivedAt     *time.Time `json:"archived_at,omitempty"`
+}
+
+// lastActive is when a memory was last accessed, or created if never.
+func (a Access) lastActive(created time.Time) time.Time {
+	if a.LastAccessedAt != nil && a.LastAccessedAt.After(created) {
+		return *a.LastAccessedAt
+	}
+	return created
+}
+
+func checkImportance(importance float64) error {
+	if importance < 0 || importance > 1 || math.IsNaN(importance) {
+		return fmt.Errorf("importance must be between 0 and 1, got %g", importance)
+	}
+	return nil
+}
+
+// touch counts an access to the rows of table with the given IDs, and
+// mirrors it in accesses.
+func touch(db DBTX, table string, ids []int64, accesses []*Access) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	args := []any{sqlTime(now)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if _, err := db.Exec(
+		`UPDATE `+table+` SET access_count = access_count + 1, last_accessed_at = ?
+		WHERE id IN (`+placeholders(len(ids))+`)`,
+		args...,
+	); err != nil {
+		return fmt.Errorf("record access to %s: %w", table, err)
+	}
+	for _, a := range accesses {
+		a.AccessCount++
+		a.LastAccessedAt = &now
+	}
+	return nil
+}
+
+// accessed counts an access to each search result, unless the store is
+// untracked, and returns them.
+func (s *ArchivalStore) accessed(results []*SearchResult) ([]*SearchResult, error) {
+	if s.peek {
+		return results, nil
+	}
+	ids := make([]int64, len(results))
+	accesses := make([]*Access, len(results))
+	for i, r := range results {
+		ids[i], accesses[i] = r.ID, &r.Access
+	}
+	if err := touch(s.db, "archival", ids, accesses); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// accessedIfRelevant counts an access to the search results that matched a
+// keyword or are within AccessSimilarity of the query, and returns them all.
+func (s *ArchivalStore) accessedIfRelevant(results []*SearchResult) ([]*SearchResult, error) {
+	var relevant []*SearchResult
+	for _, r := range results {
+		if r.LexicalRank > 0 || r.SemanticScore >= AccessSimilarity {
+			relevant = append(relevant, r)
+		}
+	}
+	if _, err := s.accessed(relevant); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DecayCurve is how a memory's retention falls with time since it was last
+// accessed.
+type DecayCurve string
+
+const (
+	// Exponential halves retention every half-life.
+	Exponential DecayCurve = "exponential"
+	// Linear takes retention down in a straight line, through one half at
+	// the half-life to zero at twice the half-life.
+	Linear DecayCurve = "linear"
+)
+
+// ForgetAction is what happens to a forgotten memory.
+type ForgetAction string
+
+const (
+	ForgetArchive ForgetAction = "archive" // hide it, restorably
+	ForgetDelete  ForgetAction = "delete"  // remove it for good
+)
+
+// ForgetPolicy decides which memories to forget. A memory's retention is
+// its importance times its decay along Curve, where each recorded access
+// stretches the half-life by another HalfLife so memories recalled often
+// fade slower. Memories with retention below Threshold that have gone
+// unaccessed for at least MinIdle are forgotten; importance 1 never is.
+type ForgetPolicy struct {
+	Action    ForgetAction
+	Curve     DecayCurve
+	HalfLife  time.Duration
+	Threshold float64
+	MinIdle   time.Duration
+}
+
+// DefaultForgetPolicy archives memories whose retention has fallen below a
+// tenth, after at least a month without access.
+var DefaultForgetPolicy = ForgetPolicy{
+	Action:    ForgetArchive,
+	Curve:     Exponential,
+	HalfLife:  90 * 24 * time.Hour,
+	Threshold: 0.1,
+	MinIdle:   30 * 24 * time.Hour,
+}
+
+// Validate reports a policy that can't be applied.
+func (p ForgetPolicy) Validate() error {
+	switch {
+	case p.Action != ForgetArchive && p.Action != ForgetDelete:
+		return fmt.Errorf("unknown forget action %q (want %s or %s)", p.Action, ForgetArchive, ForgetDelete)
+	case p.Curve != Exponential && p.Curve != Linear:
+		return fmt.Errorf("unknown decay curve %q (want %s or %s)", p.Curve, Exponential, Linear)
+	case p.HalfLife <= 0:
+		return fmt.Errorf("half-life must be positive")
+	case p.Threshold < 0 || p.Threshold > 1:
+		return fmt.Errorf("threshold must be between 0 and 1")
+	case p.MinIdle < 0:
+		return fmt.Errorf("minimum idle time must not be negative")
+	}
+	return nil
+}
+
+// Retention scores a memory from 0 to 1 by its importance, access count and
+// time since it was last active.
+func (p ForgetPolicy) Retention(importance float64, accesses int, idle time.Duration) float64 {
+	if idle < 0 {
+		idle = 0
+	}
+	age := float64(idle) / (float64(p.HalfLife) * float64(1+accesses))
+	decay := math.Pow(0.5, age)
+	if p.Curve == Linear {
+		decay = math.Max(0, 1-age/2)
+	}
+	return importance * decay
+}
+
+// Memory kinds.
+const (
+	KindFact     = "fact"
+	KindRelation = "relation"
+)
+
+// Memory is an archival entry or relation as seen by ForgetStore.
+type Memory struct {
+	Kind       string    `json:"kind"` // KindFact or KindRelation
+	ID         int64     `json:"id"`
+	Text       string    `json:"text"`
+	Importance float64   `json:"importance"`
+	CreatedAt  time.Time `json:"created_at"`
+	Retention  float64   `json:"retention"` // as scored by ForgetStore.Plan
+	Access
+}
+
+// memoryQueries select, for each kind, the columns scanned by
+// ForgetStore.memories from a table aliased "m".
+var memoryQueries = map[string]string{
+	KindFact: `SELECT m.id, m.content, m.importance, m.access_count, m.last_accessed_at, m.archived_at, m.created_at
+		FROM archival m`,
+	KindRelation: `SELECT m.id, s.name || ' -[' || m.predicate || ']-> ' || o.name,
+			m.importance, m.access_count, m.last_accessed_at, m.archived_at, m.created_at
+		FROM relations m
+		JOIN entities s ON s.id = m.subject_id
+		JOIN entities o ON o.id = m.object_id`,
+}
+
+// memoryTables are the tables holding each kind.
+var memoryTables = map[string]string{KindFact: "archival", KindRelation: "relations"}
+
+type ForgetStore struct {
+	db DBTX
+}
+
+func NewForgetStore(db DBTX) *ForgetStore {
+	return &ForgetStore{db: db}
+}
+
+// memories returns the facts, then the relations, matching cond.
+func (s *ForgetStore) memories(cond string) ([]*Memory, error) {
+	var all []*Memory
+	for _, kind := range []string{KindFact, KindRelation} {
+		rows, err := s.db.Query(memoryQueries[kind] + ` WHERE ` + cond + ` ORDER BY m.id`)
+		if err != nil {
+			return nil, fmt.Errorf("load %ss: %w", kind, err)
+		}
+		for rows.Next() {
+			m := &Memory{Kind: kind}
+			if err := rows.Scan(&m.ID, &m.Text, &m.Importance,
+				&m.AccessCount, &m.LastAccessedAt, &m.ArchivedAt, &m.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			all = append(all, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return all, nil
+}
+
+// Plan returns the live memories the policy would forget at now, lowest
+// retention first.
+func (s *ForgetStore) Plan(p ForgetPolicy, now time.Time) ([]*Memory, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	live, err := s.memories(`m.archived_at IS NULL AND m.importance < 1`)
+	if err != nil {
+		return nil, err
+	}
+	var forget []*Memory
+	for _, m := range live {
+		idle := now.Sub(m.lastActive(m.CreatedAt))
+		m.Retention = p.Retention(m.Importance, m.AccessCount, idle)
+		if idle >= p.MinIdle && m.Retention < p.Threshold {
+			forget = append(forget, m)
+		}
+	}
+	sort.SliceStable(forget, func(i, j int) bool { return forget[i].Retention < forget[j].Retention })
+	return forget, nil
+}
+
+// Forget archives or deletes memories in one transaction.
+func (s *ForgetStore) Forget(action ForgetAction, memories []*Memory) error {
+	return withTx(s.db, func(tx DBTX) error {
+		var facts []int64
+		for _, m := range memories {
+			switch {
+			case action == ForgetArchive:
+				if _, err := tx.Exec(
+					`UPDATE `+memoryTables[m.Kind]+` SET archived_at = CURRENT_TIMESTAMP WHERE id = ?`, m.ID,
+				); err != nil {
+					return fmt.Errorf("archive %s %d: %w", m.Kind, m.ID, err)
+				}
+			case m.Kind == KindFact:
+				facts = append(facts, m.ID)
+			default:
+				if err := NewGraphStore(tx).DeleteRelation(m.ID); err != nil {
+					return err
+				}
+			}
+		}
+		if len(facts) == 0 {
+			return nil
+		}
+		return NewArchivalStore(tx).Delete(facts...)
+	})
+}
+
+// Archived returns the archived memories, most recently archived first.
+func (s *ForgetStore) Archived() ([]*Memory, error) {
+	archived, err := s.memories(`m.archived_at IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(archived, func(i, j int) bool { return archived[i].ArchivedAt.After(*archived[j].ArchivedAt) })
+	return archived, nil
+}
+
+// Restore brings archived memories of a kind back, or every archived
+// memory of that kind when no IDs are given, and returns how many it
+// restored. Restoring counts as an access, so a restored memory isn't
+// forgotten again straight away.
+func (s *ForgetStore) Restore(kind string, ids ...int64) (int, error) {
+	table, ok := memoryTables[kind]
+	if !ok {
+		return 0, fmt.Errorf("unknown memory kind %q", kind)
+	}
+	q := `UPDATE ` + table + ` SET archived_at = NULL, access_count = access_count + 1, last_accessed_at = ? WHERE archived_at IS NOT NULL`
+	args := []any{sqlTime(time.Now())}
+	if len(ids) > 0 {
+		q += ` AND id IN (` + placeholders(len(ids)) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	res, err := s.db.Exec(q, args...)
+	if err != nil {
+		return 0, fmt.Errorf("restore %ss: %w", kind, err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
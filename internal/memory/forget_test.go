@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stukennedy/botmem/internal/embeddings"
+)
+
+func TestForgetPolicy_Retention(t *testing.T) {
+	day := 24 * time.Hour
+	p := ForgetPolicy{Curve: Exponential, HalfLife: 10 * day}
+	if got := p.Retention(0.8, 0, 10*day); math.Abs(got-0.4) > 1e-9 {
+		t.Errorf("expected importance halved after one half-life, got %g", got)
+	}
+	if got := p.Retention(0.8, 1, 10*day); math.Abs(got-0.8*math.Pow(0.5, 0.5)) > 1e-9 {
+		t.Errorf("expected an access to double the half-life, got %g", got)
+	}
+
+	p.Curve = Linear
+	if got := p.Retention(1, 0, 10*day); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("expected linear decay to halve at the half-life, got %g", got)
+	}
+	if got := p.Retention(1, 0, 30*day); got != 0 {
+		t.Errorf("expected linear decay to bottom out at zero, got %g", got)
+	}
+	if err := (ForgetPolicy{Action: "wipe", Curve: Linear, HalfLife: day}).Validate(); err == nil {
+		t.Error("expected an unknown action to be rejected")
+	}
+}
+
+func TestArchivalSearch_RecordsAccess(t *testing.T) {
+	store := testArchivalStore(t)
+	hit, _ := store.Add("Stu drinks green tea", nil, nil)
+	miss, _ := store.Add("Stu cycles to work", nil, nil)
+
+	results, err := store.Search(PlainQuery("tea"), 10)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("search: %d results (%v)", len(results), err)
+	}
+	if results[0].AccessCount != 1 || results[0].LastAccessedAt == nil {
+		t.Errorf("expected the result to show its access, got %+v", results[0].Access)
+	}
+	store.Search(PlainQuery("tea"), 10)
+	if e, _ := store.GetByID(hit.ID); e.AccessCount != 2 || e.LastAccessedAt == nil {
+		t.Errorf("expected 2 recorded accesses, got %+v", e.Access)
+	}
+	if e, _ := store.GetByID(miss.ID); e.AccessCount != 0 || e.LastAccessedAt != nil {
+		t.Errorf("expected entries not returned to be untouched, got %+v", e.Access)
+	}
+	tooHigh := 1.5
+	if _, err := store.Insert(NewArchival{Content: "x", Importance: &tooHigh}); err == nil {
+		t.Error("expected an out-of-range importance to be rejected")
+	}
+}
+
+func TestArchivalSearchHybrid_RecordsRelevantAccess(t *testing.T) {
+	store := testArchivalStore(t)
+	keyword, _ := store.Add("Stu drinks green tea", nil, embeddings.SerializeEmbedding([]float32{0, 1, 0}))
+	near, _ := store.Add("Stu likes oolong", nil, embeddings.SerializeEmbedding([]float32{0.9, 0.1, 0}))
+	far, _ := store.Add("Stu cycles to work", nil, embeddings.SerializeEmbedding([]float32{0, 0, 1}))
+
+	results, err := store.SearchHybrid("tea", []float32{1, 0, 0}, 10, HybridWeights{})
+	if err != nil || len(results) != 3 {
+		t.Fatalf("hybrid: %d results (%v)", len(results), err)
+	}
+	for id, want := range map[int64]int{keyword.ID: 1, near.ID: 1, far.ID: 0} {
+		if e, _ := store.GetByID(id); e.AccessCount != want {
+			t.Errorf("%q: expected %d accesses, got %d", e.Content, want, e.AccessCount)
+		}
+	}
+}
+
+func TestArchivalSearchSemantic_RecordsRelevantAccess(t *testing.T) {
+	store := testArchivalStore(t)
+	near, _ := store.Add("Stu likes oolong", nil, embeddings.SerializeEmbedding([]float32{0.9, 0.1, 0}))
+	far, _ := store.Add("Stu cycles to work", nil, embeddings.SerializeEmbedding([]float32{0, 0, 1}))
+
+	results, err := store.SearchSemantic([]float32{1, 0, 0}, 10)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("semantic: %d results (%v)", len(results), err)
+	}
+	for id, want := range map[int64]int{near.ID: 1, far.ID: 0} {
+		if e, _ := store.GetByID(id); e.AccessCount != want {
+			t.Errorf("%q: expected %d accesses, got %d", e.Content, want, e.AccessCount)
+		}
+	}
+}
+
+func TestForgetStore_ArchiveAndRestore(t *testing.T) {
+	archival := testArchivalStore(t)
+	graph := NewGraphStore(archival.db)
+	forget := NewForgetStore(archival.db)
+
+	low, high := 0.05, 1.0
+	trivial, _ := archival.Insert(NewArchival{Content: "parked on level 3", Importance: &low})
+	archival.Insert(NewArchival{Content: "born in Glasgow parking lot", Importance: &high})
+	recent, _ := archival.Insert(NewArchival{Content: "parked outside", Importance: &low})
+	relID, _, _ := graph.EnsureRelation("Stu", "visited", "car park", "")
+	graph.SetImportance(relID, 0.1)
+	archival.db.Exec(`UPDATE archival SET created_at = '2025-01-01 00:00:00'`)
+	archival.db.Exec(`UPDATE archival SET last_accessed_at = '2025-05-20 00:00:00' WHERE id = ?`, recent.ID)
+	archival.db.Exec(`UPDATE relations SET created_at = '2025-01-01 00:00:00'`)
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	plan, err := forget.Plan(DefaultForgetPolicy, now)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	// Importance 1 is kept, and so is the entry accessed within MinIdle.
+	if len(plan) != 2 || plan[0].Kind != KindFact || plan[0].ID != trivial.ID || plan[1].Kind != KindRelation {
+		t.Fatalf("expected the trivial fact and relation planned, got %+v", plan)
+	}
+	if err := forget.Forget(ForgetArchive, plan); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+
+	if results, _ := archival.Search(PlainQuery("parked"), 10); len(results) != 1 || results[0].ID != recent.ID {
+		t.Errorf("expected the archived fact hidden from search, got %d results", len(results))
+	}
+	if rels, _ := graph.QueryEntity("Stu"); len(rels) != 0 {
+		t.Errorf("expected the archived relation hidden, got %d", len(rels))
+	}
+	archivedOnly := archival.WithFilter(Filter{Archived: true})
+	if entries, _ := archivedOnly.List("", 10); len(entries) != 1 || entries[0].ArchivedAt == nil {
+		t.Errorf("expected the archived fact listed with --archived, got %d", len(entries))
+	}
+	if archived, _ := forget.Archived(); len(archived) != 2 {
+		t.Errorf("expected 2 archived memories, got %d", len(archived))
+	}
+
+	if n, err := forget.Restore(KindFact, trivial.ID); err != nil || n != 1 {
+		t.Errorf("expected 1 fact restored, got %d (%v)", n, err)
+	}
+	if e, _ := archival.GetByID(trivial.ID); e.ArchivedAt != nil || e.AccessCount != 1 || e.LastAccessedAt == nil {
+		t.Errorf("expected the fact live again and marked accessed, got %+v", e.Access)
+	}
+	// Mentioning an archived relation again brings it back.
+	graph.EnsureRelation("Stu", "visited", "car park", "")
+	if rels, _ := graph.QueryEntity("Stu"); len(rels) != 1 {
+		t.Errorf("expected the relation revived, got %d", len(rels))
+	}
+}
+
+func TestForgetStore_Delete(t *testing.T) {
+	archival := testArchivalStore(t)
+	graph := NewGraphStore(archival.db)
+	forget := NewForgetStore(archival.db)
+	e, _ := archival.Add("scratch note", []string{"scratch"}, nil)
+	relID, _, _ := graph.EnsureRelation("A", "knows", "B", "")
+
+	err := forget.Forget(ForgetDelete, []*Memory{{Kind: KindFact, ID: e.ID}, {Kind: KindRelation, ID: relID}})
+	if err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if _, err := archival.GetByID(e.ID); err == nil {
+		t.Error("expected the fact deleted")
+	}
+	if entities, _ := graph.ListEntities(""); len(entities) != 0 {
+		t.Errorf("expected the relation and its entities deleted, got %d entities", len(entities))
+	}
+	if tags, _ := NewTagStore(archival.db).List(); len(tags) != 0 {
+		t.Errorf("expected unused tags pruned, got %v", tags)
+	}
+}
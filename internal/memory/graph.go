@@ -14,22 +14,54 @@ type Entity struct {
 }
 
 type Relation struct {
-	ID        int64     `json:"id"`
-	Subject   string    `json:"subject"`
-	Predicate string    `json:"predicate"`
-	Object    string    `json:"object"`
-	Metadata  string    `json:"metadata,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int64     `json:"id"`
+	Subject    string    `json:"subject"`
+	Predicate  string    `json:"predicate"`
+	Object     string    `json:"object"`
+	Metadata   string    `json:"metadata,omitempty"`
+	Importance float64   `json:"importance"`       // 0 (trivial) to 1 (never forget)
+	RunID      *int64    `json:"run_id,omitempty"` // ingest run that created the relation
+	CreatedAt  time.Time `json:"created_at"`
+	Access
+}
+
+// relationColumns selects a relation with its entity names; queries join
+// relations r with entities s (subject) and o (object).
+const relationColumns = `r.id, s.name, r.predicate, o.name, r.metadata,
+	r.importance, r.access_count, r.last_accessed_at, r.archived_at, r.run_id, r.created_at`
+
+func scanRelations(rows *sql.Rows) ([]*Relation, error) {
+	defer rows.Close()
+
+	var rels []*Relation
+	for rows.Next() {
+		r := &Relation{}
+		if err := rows.Scan(&r.ID, &r.Subject, &r.Predicate, &r.Object, &r.Metadata,
+			&r.Importance, &r.AccessCount, &r.LastAccessedAt, &r.ArchivedAt, &r.RunID, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rels = append(rels, r)
+	}
+	return rels, rows.Err()
 }
 
 type GraphStore struct {
-	db *sql.DB
+	db   DBTX
+	peek bool // queries don't count as accesses; see Untracked
 }
 
-func NewGraphStore(db *sql.DB) *GraphStore {
+func NewGraphStore(db DBTX) *GraphStore {
 	return &GraphStore{db: db}
 }
 
+// Untracked returns a store whose queries don't count as accesses, for
+// reads such as gathering context for ingest.
+func (s *GraphStore) Untracked() *GraphStore {
+	c := *s
+	c.peek = true
+	return &c
+}
+
 // EnsureEntity creates an entity if it doesn't exist, returns its ID either way.
 func (s *GraphStore) EnsureEntity(name, entityType string) (int64, error) {
 	// Try insert, ignore conflict
@@ -51,77 +83,130 @@ func (s *GraphStore) EnsureEntity(name, entityType string) (int64, error) {
 
 // AddRelation adds a subject-predicate-object triplet.
 func (s *GraphStore) AddRelation(subject, predicate, object, metadata string) error {
+	_, _, err := s.EnsureRelation(subject, predicate, object, metadata)
+	return err
+}
+
+// EnsureRelation adds a triplet if it doesn't exist. It returns the relation's
+// ID and whether this call created it. An existing relation that 'botmem
+// forget' archived is brought back.
+func (s *GraphStore) EnsureRelation(subject, predicate, object, metadata string) (int64, bool, error) {
 	subID, err := s.EnsureEntity(subject, "")
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 	objID, err := s.EnsureEntity(object, "")
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 
-	_, err = s.db.Exec(
+	res, err := s.db.Exec(
 		`INSERT OR IGNORE INTO relations (subject_id, predicate, object_id, metadata) VALUES (?, ?, ?, ?)`,
 		subID, predicate, objID, metadata,
 	)
 	if err != nil {
-		return fmt.Errorf("add relation: %w", err)
+		return 0, false, fmt.Errorf("add relation: %w", err)
+	}
+	created, _ := res.RowsAffected()
+
+	var id int64
+	err = s.db.QueryRow(
+		`SELECT id FROM relations WHERE subject_id = ? AND predicate = ? AND object_id = ?`,
+		subID, predicate, objID,
+	).Scan(&id)
+	if err != nil {
+		return 0, false, fmt.Errorf("get relation id: %w", err)
+	}
+	if created == 0 {
+		if _, err := s.db.Exec(`UPDATE relations SET archived_at = NULL WHERE id = ?`, id); err != nil {
+			return 0, false, fmt.Errorf("unarchive relation %d: %w", id, err)
+		}
+	}
+	return id, created > 0, nil
+}
+
+// SetImportance sets a relation's importance, from 0 to 1.
+func (s *GraphStore) SetImportance(id int64, importance float64) error {
+	if err := checkImportance(importance); err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`UPDATE relations SET importance = ? WHERE id = ?`, importance, id)
+	if err != nil {
+		return fmt.Errorf("set importance of relation %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("relation %d: %w", id, sql.ErrNoRows)
 	}
 	return nil
 }
 
-// QueryEntity returns all relations where the given entity is subject or object.
+// QueryEntity returns all relations where the given entity is subject or
+// object, and records that they were accessed unless the store is untracked.
 func (s *GraphStore) QueryEntity(name string) ([]*Relation, error) {
 	rows, err := s.db.Query(
-		`SELECT r.id, s.name, r.predicate, o.name, r.metadata, r.created_at
+		`SELECT `+relationColumns+`
 		FROM relations r
 		JOIN entities s ON s.id = r.subject_id
 		JOIN entities o ON o.id = r.object_id
-		WHERE s.name = ? OR o.name = ?
+		WHERE (s.name = ? OR o.name = ?) AND r.archived_at IS NULL
 		ORDER BY r.created_at DESC`,
 		name, name,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("query entity: %w", err)
 	}
-	defer rows.Close()
+	rels, err := scanRelations(rows)
+	if err != nil || s.peek {
+		return rels, err
+	}
+	return rels, s.Touch(rels...)
+}
 
-	var rels []*Relation
-	for rows.Next() {
-		r := &Relation{}
-		if err := rows.Scan(&r.ID, &r.Subject, &r.Predicate, &r.Object, &r.Metadata, &r.CreatedAt); err != nil {
-			return nil, err
-		}
-		rels = append(rels, r)
+// ListRelations returns up to limit relations that aren't archived, most
+// important first.
+func (s *GraphStore) ListRelations(limit int) ([]*Relation, error) {
+	rows, err := s.db.Query(
+		`SELECT `+relationColumns+`
+		FROM relations r
+		JOIN entities s ON s.id = r.subject_id
+		JOIN entities o ON o.id = r.object_id
+		WHERE r.archived_at IS NULL
+		ORDER BY r.importance DESC, r.created_at DESC, r.id DESC
+		LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list relations: %w", err)
 	}
-	return rels, rows.Err()
+	return scanRelations(rows)
+}
+
+// Touch records that relations were accessed, updating both the stored
+// counts and rels.
+func (s *GraphStore) Touch(rels ...*Relation) error {
+	accesses := make([]*Access, len(rels))
+	ids := make([]int64, len(rels))
+	for i, r := range rels {
+		accesses[i], ids[i] = &r.Access, r.ID
+	}
+	return touch(s.db, "relations", ids, accesses)
 }
 
 // SearchRelations searches for relations matching a predicate pattern.
 func (s *GraphStore) SearchRelations(predicate string) ([]*Relation, error) {
 	rows, err := s.db.Query(
-		`SELECT r.id, s.name, r.predicate, o.name, r.metadata, r.created_at
+		`SELECT `+relationColumns+`
 		FROM relations r
 		JOIN entities s ON s.id = r.subject_id
 		JOIN entities o ON o.id = r.object_id
-		WHERE r.predicate LIKE ?
+		WHERE r.predicate LIKE ? AND r.archived_at IS NULL
 		ORDER BY r.created_at DESC`,
 		"%"+predicate+"%",
 	)
 	if err != nil {
 		return nil, fmt.Errorf("search relations: %w", err)
 	}
-	defer rows.Close()
-
-	var rels []*Relation
-	for rows.Next() {
-		r := &Relation{}
-		if err := rows.Scan(&r.ID, &r.Subject, &r.Predicate, &r.Object, &r.Metadata, &r.CreatedAt); err != nil {
-			return nil, err
-		}
-		rels = append(rels, r)
-	}
-	return rels, rows.Err()
+	return scanRelations(rows)
 }
 
 // ListEntities returns all entities, optionally filtered by type.
@@ -150,3 +235,45 @@ func (s *GraphStore) ListEntities(entityType string) ([]*Entity, error) {
 	}
 	return entities, rows.Err()
 }
+
+// ByRun returns the relations created by an ingest run.
+func (s *GraphStore) ByRun(runID int64) ([]*Relation, error) {
+	rows, err := s.db.Query(
+		`SELECT `+relationColumns+`
+		FROM relations r
+		JOIN entities s ON s.id = r.subject_id
+		JOIN entities o ON o.id = r.object_id
+		WHERE r.run_id = ?
+		ORDER BY r.id`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("relations for run %d: %w", runID, err)
+	}
+	return scanRelations(rows)
+}
+
+// DeleteRelation removes a relation, along with its subject and object
+// entities if they no longer take part in any relation.
+func (s *GraphStore) DeleteRelation(id int64) error {
+	return withTx(s.db, func(tx DBTX) error {
+		var subID, objID int64
+		err := tx.QueryRow(`SELECT subject_id, object_id FROM relations WHERE id = ?`, id).Scan(&subID, &objID)
+		if err != nil {
+			return fmt.Errorf("get relation %d: %w", id, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM relations WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("delete relation %d: %w", id, err)
+		}
+		_, err = tx.Exec(
+			`DELETE FROM entities WHERE id IN (?, ?) AND NOT EXISTS (
+				SELECT 1 FROM relations WHERE subject_id = entities.id OR object_id = entities.id
+			)`,
+			subID, objID,
+		)
+		if err != nil {
+			return fmt.Errorf("prune entities: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Metadata holds caller-defined fields of an archival entry — author,
+// URL, conversation ID and the like — stored as a JSON object in
+// archival.metadata. Values are any JSON value.
+type Metadata map[string]any
+
+// Value stores m as a JSON object, or NULL when it is empty.
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[string]any(m))
+	if err != nil {
+		return nil, fmt.Errorf("encode metadata: %w", err)
+	}
+	return string(b), nil
+}
+
+// Scan reads a JSON object stored by Value.
+func (m *Metadata) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("scan metadata: unexpected %T", src)
+	}
+	*m = nil
+	if len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, (*map[string]any)(m)); err != nil {
+		return fmt.Errorf("scan metadata: %w", err)
+	}
+	return nil
+}
+
+// Keys returns m's keys in order.
+func (m Metadata) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String formats m as "k=v, ..." with keys in order, for display.
+func (m Metadata) String() string {
+	parts := make([]string, 0, len(m))
+	for _, k := range m.Keys() {
+		parts = append(parts, k+"="+MetaValueString(m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MetaValueString formats a metadata value: strings as they are, anything
+// else as JSON.
+func MetaValueString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// ParseMetaValue reads a value given on the command line: a JSON number,
+// boolean, null, array or object when it parses as one, otherwise the text
+// itself.
+func ParseMetaValue(text string) any {
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err == nil {
+		if _, isString := v.(string); !isString {
+			return v
+		}
+	}
+	return text
+}
+
+// MetaMatch is a condition on an entry's metadata. Key may be a dotted path
+// into nested objects ("author.name"). With HasValue unset the key only has
+// to be present.
+type MetaMatch struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// ParseMetaMatch parses "key=value", or "key" for presence.
+func ParseMetaMatch(text string) (MetaMatch, error) {
+	key, value, hasValue := strings.Cut(text, "=")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return MetaMatch{}, fmt.Errorf("invalid metadata condition %q: want key=value", text)
+	}
+	for _, seg := range strings.Split(key, ".") {
+		if seg == "" || strings.Contains(seg, `"`) {
+			return MetaMatch{}, fmt.Errorf("invalid metadata key %q", key)
+		}
+	}
+	return MetaMatch{Key: key, Value: value, HasValue: hasValue}, nil
+}
+
+// clause returns a condition on archival alias "a". Values compare as
+// text, so "year=2024" matches the number 2024 as well as the string;
+// true and false match JSON booleans.
+func (m MetaMatch) clause() (string, []any) {
+	path := metaPath(m.Key)
+	if !m.HasValue {
+		return `json_type(a.metadata, ?) IS NOT NULL`, []any{path}
+	}
+	value := m.Value
+	switch value {
+	case "true":
+		return `(json_type(a.metadata, ?) = 'true' OR json_extract(a.metadata, ?) = 'true')`, []any{path, path}
+	case "false":
+		return `(json_type(a.metadata, ?) = 'false' OR json_extract(a.metadata, ?) = 'false')`, []any{path, path}
+	}
+	return `CAST(json_extract(a.metadata, ?) AS TEXT) = ?`, []any{path, value}
+}
+
+// metaPath returns the JSON path of a dotted key, quoting each segment so
+// keys may contain spaces and punctuation other than "." and '"'.
+func metaPath(key string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range strings.Split(key, ".") {
+		b.WriteString(`."` + seg + `"`)
+	}
+	return b.String()
+}
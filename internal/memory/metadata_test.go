@@ -0,0 +1,91 @@
+package memory
+
+import "testing"
+
+func TestArchivalMetadata_Where(t *testing.T) {
+	store := testArchivalStore(t)
+	a, err := store.Insert(NewArchival{
+		Content:  "Q3 roadmap agreed",
+		Source:   "https://notes.example/q3",
+		Metadata: Metadata{"author": "chris", "year": float64(2024), "reviewed": true, "team": map[string]any{"name": "voice"}},
+	})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	store.Insert(NewArchival{Content: "Q4 roadmap drafted", Metadata: Metadata{"author": "stu", "year": "2025"}})
+	store.Add("roadmap without metadata", nil, nil)
+
+	got, err := store.GetByID(a.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Source != "https://notes.example/q3" || got.Metadata["author"] != "chris" || got.Metadata["reviewed"] != true {
+		t.Errorf("expected source and metadata round-tripped, got %q %v", got.Source, got.Metadata)
+	}
+
+	tests := []struct {
+		where []string
+		want  int
+	}{
+		{[]string{"author=chris"}, 1},
+		{[]string{"author"}, 2},
+		{[]string{"year=2024"}, 1},
+		{[]string{"year=2025"}, 1},
+		{[]string{"reviewed=true"}, 1},
+		{[]string{"team.name=voice"}, 1},
+		{[]string{"author=stu", "year=2024"}, 0},
+		{[]string{"missing"}, 0},
+	}
+	for _, tt := range tests {
+		var f Filter
+		for _, w := range tt.where {
+			m, err := ParseMetaMatch(w)
+			if err != nil {
+				t.Fatalf("parse %q: %v", w, err)
+			}
+			f.Where = append(f.Where, m)
+		}
+		listed, err := store.WithFilter(f).List("", 50)
+		if err != nil {
+			t.Fatalf("list %v: %v", tt.where, err)
+		}
+		if len(listed) != tt.want {
+			t.Errorf("list --where %v: expected %d entries, got %d", tt.where, tt.want, len(listed))
+		}
+		results, err := store.WithFilter(f).Search(PlainQuery("roadmap"), 10)
+		if err != nil {
+			t.Fatalf("search %v: %v", tt.where, err)
+		}
+		if len(results) != tt.want {
+			t.Errorf("search --where %v: expected %d results, got %d", tt.where, tt.want, len(results))
+		}
+	}
+
+	if _, err := ParseMetaMatch("=x"); err == nil {
+		t.Error("expected an error for a condition without a key")
+	}
+}
+
+func TestArchivalMetadata_Patch(t *testing.T) {
+	store := testArchivalStore(t)
+	e, _ := store.Insert(NewArchival{Content: "fact", Source: "doc.md", SourceOffset: 40, Metadata: Metadata{"author": "stu"}})
+
+	tagged, err := store.Update(e.ID, ArchivalPatch{Tags: []string{"work"}})
+	if err != nil || tagged.Metadata["author"] != "stu" || tagged.Source != "doc.md" {
+		t.Errorf("expected metadata and source kept, got %q %v (%v)", tagged.Source, tagged.Metadata, err)
+	}
+
+	source := "conversation-42"
+	moved, err := store.Update(e.ID, ArchivalPatch{Source: &source, Metadata: Metadata{"author": "chris", "turn": float64(3)}})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if moved.Source != source || moved.SourceOffset != 0 || moved.Metadata["author"] != "chris" || len(moved.Metadata) != 2 {
+		t.Errorf("expected source and metadata replaced, got %q@%d %v", moved.Source, moved.SourceOffset, moved.Metadata)
+	}
+
+	cleared, err := store.Update(e.ID, ArchivalPatch{Metadata: Metadata{}})
+	if err != nil || cleared.Metadata != nil {
+		t.Errorf("expected metadata removed, got %v (%v)", cleared.Metadata, err)
+	}
+}
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stukennedy/botmem/internal/db"
+)
+
+// ErrQuerySyntax is returned when a raw FTS5 query cannot be parsed.
+var ErrQuerySyntax = errors.New("invalid full-text query")
+
+// PlainQuery turns free text, such as an agent's question, into an FTS5
+// query matching entries that contain every word of it. Each
+// whitespace-separated word is quoted as a phrase, so punctuation and FTS5
+// operators (C++, "rollout, what's next?, AND) are searched for as text
+// rather than parsed. Words with no letters or digits are dropped; text with
+// none at all gives "", which matches nothing.
+func PlainQuery(text string) string {
+	var terms []string
+	for _, w := range strings.Fields(text) {
+		if !strings.ContainsFunc(w, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// ftsError wraps an error from an FTS5 MATCH, reporting query syntax errors
+// as ErrQuerySyntax.
+func ftsError(query string, err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "fts5:") || strings.Contains(msg, "unterminated string") || strings.Contains(msg, "no such column") {
+		return fmt.Errorf("%w %q: %s", ErrQuerySyntax, query, strings.TrimPrefix(msg, "SQL logic error: "))
+	}
+	return err
+}
+
+// maxFuzzyTerms caps how many indexed terms one query word can expand to.
+const maxFuzzyTerms = 10
+
+// FuzzyQuery builds a lenient FTS5 query from free text, for when the
+// PlainQuery of it finds nothing. Any word may match, and each word also
+// matches indexed terms that contain it (so part of a run of CJK text finds
+// the run) or that are within a typo or two of it. Under the trigram
+// tokenizer, a word instead matches entries sharing any three-character
+// run with it. FuzzyQuery returns "" when nothing can match.
+func (s *ArchivalStore) FuzzyQuery(text string) (string, error) {
+	tokenizer, err := db.Tokenizer(s.db)
+	if err != nil {
+		return "", err
+	}
+
+	var terms []string
+	seen := map[string]bool{}
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+		}
+	}
+	for _, w := range words(text) {
+		if tokenizer == db.Trigram {
+			r := []rune(w)
+			for i := 0; i+3 <= len(r); i++ {
+				add(string(r[i : i+3]))
+			}
+			continue
+		}
+		near, err := s.nearTerms(w)
+		if err != nil {
+			return "", err
+		}
+		for _, t := range near {
+			add(t)
+		}
+	}
+	return strings.Join(terms, " OR "), nil
+}
+
+// words splits text into lowercase runs of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nearTerms returns the indexed terms that start with w, then those that
+// contain it or are within fuzzyEdits(w) edits of it, closest first. Typos
+// are only looked for among terms sharing w's first letter, a range the
+// vocabulary can seek to, and the prefix and substring lookups stop after a
+// few terms, so large indexes aren't scanned in full for every word.
+func (s *ArchivalStore) nearTerms(w string) ([]string, error) {
+	r := []rune(w)
+	n := len(r)
+	edits := fuzzyEdits(n)
+	var candidates []string
+	for _, q := range []struct {
+		where string
+		args  []any
+	}{
+		{`term >= ? AND term < ? LIMIT ?`, []any{w, w + string(utf8.MaxRune), maxFuzzyTerms}},
+		{`term >= ? AND term < ? AND length(term) BETWEEN ? AND ?`, []any{string(r[0]), string(r[0] + 1), n - edits, n + edits}},
+		{`term LIKE ? ESCAPE '\' LIMIT ?`, []any{"%" + likeEscape(w) + "%", maxFuzzyTerms}},
+	} {
+		terms, err := s.vocabTerms(q.where, q.args...)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, terms...)
+	}
+
+	type near struct {
+		term string
+		dist int
+	}
+	var found []near
+	seen := map[string]bool{}
+	for _, t := range candidates {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		switch {
+		case strings.HasPrefix(t, w):
+			found = append(found, near{t, 0})
+		case strings.Contains(t, w):
+			found = append(found, near{t, 1})
+		default:
+			if d := editDistance(w, t); d <= edits {
+				found = append(found, near{t, d})
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+	if len(found) > maxFuzzyTerms {
+		found = found[:maxFuzzyTerms]
+	}
+	terms := make([]string, len(found))
+	for i, f := range found {
+		terms[i] = f.term
+	}
+	return terms, nil
+}
+
+// vocabTerms returns the indexed terms matching a condition on the
+// vocabulary's term column.
+func (s *ArchivalStore) vocabTerms(where string, args ...any) ([]string, error) {
+	rows, err := s.db.Query(`SELECT term FROM archival_vocab WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read index terms: %w", err)
+	}
+	defer rows.Close()
+	var terms []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
+
+// fuzzyEdits is how many typos a word of n characters may contain: none in
+// very short words, where one edit reaches too many unrelated terms.
+func fuzzyEdits(n int) int {
+	switch {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
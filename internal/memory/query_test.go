@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stukennedy/botmem/internal/db"
+)
+
+func TestPlainQuery(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add(`Learning C++ before the "rollout" next week`, nil, nil)
+	store.Add("what's next? plan the launch", nil, nil)
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{`C++ "rollout`, 1},
+		{"what's next?", 1},
+		{"next", 2},
+		{"AND OR NOT", 0},
+		{"tags:launch", 0},
+		{"???", 0},
+	}
+	for _, tt := range tests {
+		got, err := store.Search(PlainQuery(tt.text), 10)
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		if len(got) != tt.want {
+			t.Errorf("%q (%s): expected %d results, got %d", tt.text, PlainQuery(tt.text), tt.want, len(got))
+		}
+	}
+}
+
+func TestSearch_RawSyntaxError(t *testing.T) {
+	store := testArchivalStore(t)
+	store.Add("anything", nil, nil)
+
+	for _, raw := range []string{`C++ "rollout`, "what's next?", "AND", "foo:bar"} {
+		if _, err := store.Search(raw, 10); !errors.Is(err, ErrQuerySyntax) {
+			t.Errorf("%q: expected ErrQuerySyntax, got %v", raw, err)
+		}
+		if _, err := store.Select(raw); !errors.Is(err, ErrQuerySyntax) {
+			t.Errorf("%q: expected ErrQuerySyntax from Select, got %v", raw, err)
+		}
+	}
+}
+
+func TestFuzzyQuery(t *testing.T) {
+	store := testArchivalStore(t)
+	contracts, _ := store.Add("Stu prefers outside contracts", nil, nil)
+	tokyo, _ := store.Add("会议在东京举行", nil, nil)
+
+	tests := []struct {
+		text string
+		want int64
+	}{
+		{"contrcts", contracts.ID},       // a typo
+		{"contr", contracts.ID},          // a partial word
+		{"racts", contracts.ID},          // the middle of a word
+		{"东京", tokyo.ID},                 // part of an unspaced CJK run
+		{"outsde the box", contracts.ID}, // any word may match
+	}
+	for _, tt := range tests {
+		if got, _ := store.Search(PlainQuery(tt.text), 10); len(got) != 0 {
+			t.Errorf("%q: expected no exact match, got %d", tt.text, len(got))
+		}
+		q, err := store.FuzzyQuery(tt.text)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.text, err)
+		}
+		got, err := store.Search(q, 10)
+		if err != nil || len(got) == 0 || got[0].ID != tt.want {
+			t.Errorf("%q (%s): expected entry %d first, got %v (%v)", tt.text, q, tt.want, got, err)
+		}
+	}
+
+	if q, _ := store.FuzzyQuery("zzzzzz"); q != "" {
+		t.Errorf("expected no query when nothing is near, got %q", q)
+	}
+}
+
+func TestFuzzyQuery_Trigram(t *testing.T) {
+	store := testArchivalStore(t)
+	if err := db.SetTokenizer(store.db.(*sql.DB), db.Trigram); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := store.Add("Stu prefers outside contracts", nil, nil)
+
+	q, err := store.FuzzyQuery("contrcts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Search(q, 10); err != nil || len(got) != 1 || got[0].ID != e.ID {
+		t.Errorf("expected shared trigrams to find the entry with %s, got %v (%v)", q, got, err)
+	}
+}
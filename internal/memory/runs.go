@@ -0,0 +1,395 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IngestRun records one execution of the ingest pipeline so every memory it
+// wrote can be traced back to the LLM output that produced it.
+type IngestRun struct {
+	ID        int64      `json:"id"`
+	Provider  string     `json:"provider"`
+	Model     string     `json:"model"`
+	InputHash string     `json:"input_hash"`
+	RawOutput string     `json:"raw_output"`
+	CreatedAt time.Time  `json:"created_at"`
+	UndoneAt  *time.Time `json:"undone_at,omitempty"`
+}
+
+// ErrRunUndone is returned when undoing a run that has already been undone.
+var ErrRunUndone = errors.New("ingest run already undone")
+
+// UndoResult reports what undoing a run removed and restored. Blocks and
+// facts edited since the run are left alone and listed as skipped.
+type UndoResult struct {
+	RunID          int64    `json:"run_id"`
+	Facts          int      `json:"facts_deleted"`
+	Relations      int      `json:"relations_deleted"`
+	Summaries      int      `json:"summaries_deleted"`
+	FactsRestored  int      `json:"facts_restored"`
+	FactsSkipped   []int64  `json:"facts_skipped,omitempty"`
+	BlocksRestored []string `json:"blocks_restored,omitempty"`
+	BlocksDeleted  []string `json:"blocks_deleted,omitempty"`
+	BlocksSkipped  []string `json:"blocks_skipped,omitempty"`
+}
+
+// BlockChange is a memory block write made by an ingest run.
+// PreviousContent is nil when the run created the block.
+type BlockChange struct {
+	ID              int64   `json:"id"`
+	RunID           int64   `json:"run_id"`
+	Label           string  `json:"label"`
+	PreviousContent *string `json:"previous_content,omitempty"`
+	NewContent      string  `json:"new_content"`
+}
+
+// FactChange is an update or delete of an existing archival entry made by
+// an ingest run. Previous holds the entry as it was before the change;
+// NewContent is nil for deletes.
+type FactChange struct {
+	ID         int64          `json:"id"`
+	RunID      int64          `json:"run_id"`
+	ArchivalID int64          `json:"archival_id"`
+	Op         string         `json:"op"`
+	Previous   *ArchivalEntry `json:"previous"`
+	NewContent *string        `json:"new_content,omitempty"`
+}
+
+// RunItems is everything an ingest run produced.
+type RunItems struct {
+	Run          *IngestRun       `json:"run"`
+	BlockChanges []*BlockChange   `json:"block_changes"`
+	FactChanges  []*FactChange    `json:"fact_changes"`
+	Facts        []*ArchivalEntry `json:"facts"`
+	Relations    []*Relation      `json:"relations"`
+	Summaries    []*Summary       `json:"summaries"`
+}
+
+// runColumns are the ingest_runs columns read by scanRun.
+const runColumns = `id, provider, model, input_hash, raw_output, created_at, undone_at`
+
+func scanRun(row rowScanner) (*IngestRun, error) {
+	r := &IngestRun{}
+	if err := row.Scan(&r.ID, &r.Provider, &r.Model, &r.InputHash, &r.RawOutput, &r.CreatedAt, &r.UndoneAt); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type RunStore struct {
+	db DBTX
+}
+
+func NewRunStore(db DBTX) *RunStore {
+	return &RunStore{db: db}
+}
+
+func (s *RunStore) Create(provider, model, inputHash, rawOutput string) (*IngestRun, error) {
+	res, err := s.db.Exec(
+		`INSERT INTO ingest_runs (provider, model, input_hash, raw_output) VALUES (?, ?, ?, ?)`,
+		provider, model, inputHash, rawOutput,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create ingest run: %w", err)
+	}
+	id, _ := res.LastInsertId()
+	return s.GetByID(id)
+}
+
+func (s *RunStore) GetByID(id int64) (*IngestRun, error) {
+	r, err := scanRun(s.db.QueryRow(`SELECT `+runColumns+` FROM ingest_runs WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("get ingest run %d: %w", id, err)
+	}
+	return r, nil
+}
+
+// List returns the most recent runs first.
+func (s *RunStore) List(limit int) ([]*IngestRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.db.Query(
+		`SELECT `+runColumns+` FROM ingest_runs ORDER BY id DESC LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list ingest runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*IngestRun
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// Latest returns the most recent run that has not been undone.
+func (s *RunStore) Latest() (*IngestRun, error) {
+	r, err := scanRun(s.db.QueryRow(
+		`SELECT ` + runColumns + ` FROM ingest_runs WHERE undone_at IS NULL ORDER BY id DESC LIMIT 1`,
+	))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no ingest runs to undo")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("latest ingest run: %w", err)
+	}
+	return r, nil
+}
+
+// LinkArchival marks an archival entry as created by a run, and records its
+// content so Undo can tell whether it has been edited since.
+func (s *RunStore) LinkArchival(runID, archivalID int64) error {
+	if err := s.link("archival", runID, archivalID); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`UPDATE archival SET run_content = content WHERE id = ?`, archivalID); err != nil {
+		return fmt.Errorf("record content of archival %d: %w", archivalID, err)
+	}
+	return nil
+}
+
+// LinkRelation marks a relation as created by a run.
+func (s *RunStore) LinkRelation(runID, relationID int64) error {
+	return s.link("relations", runID, relationID)
+}
+
+// LinkSummary marks a conversation summary as created by a run.
+func (s *RunStore) LinkSummary(runID, summaryID int64) error {
+	return s.link("conversation_summaries", runID, summaryID)
+}
+
+func (s *RunStore) link(table string, runID, id int64) error {
+	if _, err := s.db.Exec(`UPDATE `+table+` SET run_id = ? WHERE id = ?`, runID, id); err != nil {
+		return fmt.Errorf("link %s %d to run %d: %w", table, id, runID, err)
+	}
+	return nil
+}
+
+// RecordBlockChange stores a block write made by a run. Pass a nil previous
+// when the run created the block.
+func (s *RunStore) RecordBlockChange(runID int64, label string, previous *string, newContent string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO ingest_block_changes (run_id, label, previous_content, new_content) VALUES (?, ?, ?, ?)`,
+		runID, label, previous, newContent,
+	)
+	if err != nil {
+		return fmt.Errorf("record block change: %w", err)
+	}
+	return nil
+}
+
+// BlockChanges returns the block writes made by a run, in order.
+func (s *RunStore) BlockChanges(runID int64) ([]*BlockChange, error) {
+	rows, err := s.db.Query(
+		`SELECT id, run_id, label, previous_content, new_content FROM ingest_block_changes
+		WHERE run_id = ? ORDER BY id`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("block changes for run %d: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var changes []*BlockChange
+	for rows.Next() {
+		c := &BlockChange{}
+		if err := rows.Scan(&c.ID, &c.RunID, &c.Label, &c.PreviousContent, &c.NewContent); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// RecordFactChange stores an update or delete of an existing archival entry
+// made by a run. previous is the entry before the change, including its
+// embedding; pass a nil newContent for deletes.
+func (s *RunStore) RecordFactChange(runID int64, op string, previous *ArchivalEntry, newContent *string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO ingest_fact_changes (run_id, archival_id, op, previous_content, previous_tags,
+			previous_embedding, previous_embedding_model, previous_embedding_dim,
+			previous_source, previous_source_offset, previous_metadata,
+			previous_importance, previous_access_count, previous_last_accessed_at,
+			previous_run_id, previous_created_at, new_content)
+		VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, ?)`,
+		runID, previous.ID, op, previous.Content, previous.Tags,
+		previous.Embedding, previous.EmbeddingModel, previous.EmbeddingDim,
+		previous.Source, sourceOffset(previous), previous.Metadata,
+		previous.Importance, previous.AccessCount, previous.LastAccessedAt,
+		previous.RunID, previous.CreatedAt, newContent,
+	)
+	if err != nil {
+		return fmt.Errorf("record fact change: %w", err)
+	}
+	return nil
+}
+
+// FactChanges returns the archival updates and deletes made by a run, in order.
+func (s *RunStore) FactChanges(runID int64) ([]*FactChange, error) {
+	rows, err := s.db.Query(
+		`SELECT id, run_id, archival_id, op, previous_content, previous_tags,
+			previous_embedding, COALESCE(previous_embedding_model, ''),
+			COALESCE(previous_embedding_dim, length(previous_embedding) / 4, 0),
+			COALESCE(previous_source, ''), COALESCE(previous_source_offset, 0), previous_metadata,
+			COALESCE(previous_importance, 0.5), COALESCE(previous_access_count, 0), previous_last_accessed_at,
+			previous_run_id, previous_created_at, new_content
+		FROM ingest_fact_changes WHERE run_id = ? ORDER BY id`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fact changes for run %d: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var changes []*FactChange
+	for rows.Next() {
+		c := &FactChange{Previous: &ArchivalEntry{}}
+		p := c.Previous
+		if err := rows.Scan(&c.ID, &c.RunID, &c.ArchivalID, &c.Op, &p.Content, &p.Tags,
+			&p.Embedding, &p.EmbeddingModel, &p.EmbeddingDim, &p.Source, &p.SourceOffset, &p.Metadata,
+			&p.Importance, &p.AccessCount, &p.LastAccessedAt,
+			&p.RunID, &p.CreatedAt, &c.NewContent); err != nil {
+			return nil, err
+		}
+		p.ID = c.ArchivalID
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// Items collects everything a run produced across the memory stores.
+func (s *RunStore) Items(runID int64) (*RunItems, error) {
+	run, err := s.GetByID(runID)
+	if err != nil {
+		return nil, err
+	}
+	items := &RunItems{Run: run}
+	if items.BlockChanges, err = s.BlockChanges(runID); err != nil {
+		return nil, err
+	}
+	if items.FactChanges, err = s.FactChanges(runID); err != nil {
+		return nil, err
+	}
+	if items.Facts, err = NewArchivalStore(s.db).ByRun(runID); err != nil {
+		return nil, err
+	}
+	if items.Relations, err = NewGraphStore(s.db).ByRun(runID); err != nil {
+		return nil, err
+	}
+	if items.Summaries, err = NewSummaryStore(s.db).ByRun(runID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Undo reverts an ingest run: the facts, relations and summaries it created
+// are deleted, facts it updated or deleted are put back, and the blocks it
+// wrote are restored to their previous content (or deleted, if the run
+// created them). A block or fact that has changed since the run is skipped
+// rather than clobbered. The run record is kept and marked undone.
+func (s *RunStore) Undo(runID int64) (*UndoResult, error) {
+	res := &UndoResult{RunID: runID}
+	err := withTx(s.db, func(tx DBTX) error {
+		runs := NewRunStore(tx)
+		items, err := runs.Items(runID)
+		if err != nil {
+			return err
+		}
+		if items.Run.UndoneAt != nil {
+			return fmt.Errorf("run %d: %w", runID, ErrRunUndone)
+		}
+
+		archival := NewArchivalStore(tx)
+		for i := len(items.FactChanges) - 1; i >= 0; i-- {
+			c := items.FactChanges[i]
+			current, err := archival.GetByID(c.ArchivalID)
+			switch {
+			case c.Op == "delete" && err != nil:
+				if err := archival.restore(c.Previous); err != nil {
+					return err
+				}
+			case c.Op == "update" && err == nil && c.NewContent != nil && current.Content == *c.NewContent:
+				if err := archival.revert(c.Previous); err != nil {
+					return err
+				}
+			default:
+				res.FactsSkipped = append(res.FactsSkipped, c.ArchivalID)
+				continue
+			}
+			res.FactsRestored++
+		}
+		for _, e := range items.Facts {
+			var created sql.NullString
+			if err := tx.QueryRow(`SELECT run_content FROM archival WHERE id = ?`, e.ID).Scan(&created); err != nil {
+				return fmt.Errorf("load archival %d: %w", e.ID, err)
+			}
+			// Facts linked before run_content was recorded can't be checked.
+			if created.Valid && created.String != e.Content {
+				res.FactsSkipped = append(res.FactsSkipped, e.ID)
+				continue
+			}
+			if err := archival.Delete(e.ID); err != nil {
+				return fmt.Errorf("delete archival %d: %w", e.ID, err)
+			}
+			res.Facts++
+		}
+
+		graph := NewGraphStore(tx)
+		for _, r := range items.Relations {
+			if err := graph.DeleteRelation(r.ID); err != nil {
+				return err
+			}
+		}
+		res.Relations = len(items.Relations)
+
+		summaries := NewSummaryStore(tx)
+		for _, sm := range items.Summaries {
+			if err := summaries.Delete(sm.ID); err != nil {
+				return fmt.Errorf("delete summary %d: %w", sm.ID, err)
+			}
+		}
+		res.Summaries = len(items.Summaries)
+
+		// Walk block changes newest first so a label written twice by the
+		// run ends up with the content it had before the run.
+		blocks := NewBlockStore(tx)
+		for i := len(items.BlockChanges) - 1; i >= 0; i-- {
+			c := items.BlockChanges[i]
+			current, err := blocks.GetByLabel(c.Label)
+			if err != nil || current.Content != c.NewContent {
+				res.BlocksSkipped = append(res.BlocksSkipped, c.Label)
+				continue
+			}
+			if c.PreviousContent == nil {
+				if err := blocks.Delete(c.Label); err != nil {
+					return fmt.Errorf("delete block %q: %w", c.Label, err)
+				}
+				res.BlocksDeleted = append(res.BlocksDeleted, c.Label)
+				continue
+			}
+			if _, err := blocks.Update(c.Label, *c.PreviousContent); err != nil {
+				return err
+			}
+			res.BlocksRestored = append(res.BlocksRestored, c.Label)
+		}
+
+		if _, err := tx.Exec(`UPDATE ingest_runs SET undone_at = CURRENT_TIMESTAMP WHERE id = ?`, runID); err != nil {
+			return fmt.Errorf("mark run %d undone: %w", runID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
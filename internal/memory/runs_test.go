@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stukennedy/botmem/internal/db"
+)
+
+func testRunStore(t *testing.T) *RunStore {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return NewRunStore(database)
+}
+
+func TestRunCreate(t *testing.T) {
+	store := testRunStore(t)
+	r, err := store.Create("ollama", "llama3.2", "abc123", `{"facts":[]}`)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if r.Provider != "ollama" || r.Model != "llama3.2" || r.InputHash != "abc123" {
+		t.Errorf("unexpected run: %+v", r)
+	}
+	if r.RawOutput != `{"facts":[]}` {
+		t.Errorf("unexpected raw output: %q", r.RawOutput)
+	}
+}
+
+func TestRunList_MostRecentFirst(t *testing.T) {
+	store := testRunStore(t)
+	store.Create("claude", "claude", "a", "")
+	store.Create("claude", "claude", "b", "")
+
+	runs, err := store.List(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].InputHash != "b" {
+		t.Errorf("expected newest run first, got %+v", runs)
+	}
+}
+
+func TestRunItems_LinksAcrossStores(t *testing.T) {
+	store := testRunStore(t)
+	run, _ := store.Create("claude", "claude", "h", "")
+
+	archival := NewArchivalStore(store.db)
+	linked, _ := archival.Add("from run", nil, nil)
+	archival.Add("added by hand", nil, nil)
+	store.LinkArchival(run.ID, linked.ID)
+
+	relID, _, _ := NewGraphStore(store.db).EnsureRelation("A", "knows", "B", "")
+	store.LinkRelation(run.ID, relID)
+
+	sm, _ := NewSummaryStore(store.db).Add(0, "summary", "")
+	store.LinkSummary(run.ID, sm.ID)
+
+	prev := "old"
+	store.RecordBlockChange(run.ID, "human", &prev, "new")
+
+	items, err := store.Items(run.ID)
+	if err != nil {
+		t.Fatalf("items: %v", err)
+	}
+	if len(items.Facts) != 1 || items.Facts[0].Content != "from run" {
+		t.Errorf("expected only the linked fact, got %+v", items.Facts)
+	}
+	if items.Facts[0].RunID == nil || *items.Facts[0].RunID != run.ID {
+		t.Errorf("expected fact RunID %d, got %v", run.ID, items.Facts[0].RunID)
+	}
+	if len(items.Relations) != 1 || len(items.Summaries) != 1 || len(items.BlockChanges) != 1 {
+		t.Errorf("unexpected items: %+v", items)
+	}
+}
+
+func TestEnsureRelation_ReportsCreation(t *testing.T) {
+	graph := NewGraphStore(testRunStore(t).db)
+	id1, created, err := graph.EnsureRelation("A", "knows", "B", "")
+	if err != nil || !created {
+		t.Fatalf("expected first call to create, got created=%v err=%v", created, err)
+	}
+	id2, created, err := graph.EnsureRelation("A", "knows", "B", "")
+	if err != nil || created {
+		t.Fatalf("expected second call not to create, got created=%v err=%v", created, err)
+	}
+	if id1 != id2 {
+		t.Errorf("expected same ID, got %d and %d", id1, id2)
+	}
+}
+
+func TestRunUndo_RevertsEverything(t *testing.T) {
+	store := testRunStore(t)
+	blocks := NewBlockStore(store.db)
+	blocks.Create("human", "core", "before")
+	blocks.Create("persona", "core", "friendly")
+	NewArchivalStore(store.db).Add("kept", nil, nil)
+
+	run, _ := store.Create("claude", "claude", "h", "")
+	fact, _ := NewArchivalStore(store.db).Add("garbage", nil, nil)
+	store.LinkArchival(run.ID, fact.ID)
+	relID, _, _ := NewGraphStore(store.db).EnsureRelation("A", "knows", "B", "")
+	store.LinkRelation(run.ID, relID)
+	sm, _ := NewSummaryStore(store.db).Add(0, "summary", "")
+	store.LinkSummary(run.ID, sm.ID)
+
+	prev, persona := "before", "friendly"
+	blocks.Update("human", "after")
+	store.RecordBlockChange(run.ID, "human", &prev, "after")
+	blocks.Create("context", "core", "new")
+	store.RecordBlockChange(run.ID, "context", nil, "new")
+	store.RecordBlockChange(run.ID, "persona", &persona, "grumpy")
+	blocks.Update("persona", "edited by hand")
+
+	res, err := store.Undo(run.ID)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if res.Facts != 1 || res.Relations != 1 || res.Summaries != 1 {
+		t.Errorf("unexpected counts: %+v", res)
+	}
+	if len(res.BlocksSkipped) != 1 || res.BlocksSkipped[0] != "persona" {
+		t.Errorf("expected persona skipped, got %v", res.BlocksSkipped)
+	}
+
+	if b, _ := blocks.GetByLabel("human"); b.Content != "before" {
+		t.Errorf("expected human restored, got %q", b.Content)
+	}
+	if _, err := blocks.GetByLabel("context"); err == nil {
+		t.Error("expected block created by the run to be deleted")
+	}
+	if b, _ := blocks.GetByLabel("persona"); b.Content != "edited by hand" {
+		t.Errorf("expected later edit kept, got %q", b.Content)
+	}
+	if entries, _ := NewArchivalStore(store.db).List("", 10); len(entries) != 1 || entries[0].Content != "kept" {
+		t.Errorf("expected only the unrelated fact left, got %+v", entries)
+	}
+	if entities, _ := NewGraphStore(store.db).ListEntities(""); len(entities) != 0 {
+		t.Errorf("expected orphaned entities pruned, got %d", len(entities))
+	}
+	if n, _ := NewSummaryStore(store.db).CountAtLevel(0); n != 0 {
+		t.Errorf("expected summary deleted, got %d", n)
+	}
+
+	if r, _ := store.GetByID(run.ID); r.UndoneAt == nil {
+		t.Error("expected run marked undone")
+	}
+	if _, err := store.Undo(run.ID); !errors.Is(err, ErrRunUndone) {
+		t.Errorf("expected ErrRunUndone on second undo, got %v", err)
+	}
+	if _, err := store.Latest(); err == nil {
+		t.Error("expected no undoable runs left")
+	}
+}
+
+func TestRunUndo_SkipsFactsEditedSinceTheRun(t *testing.T) {
+	store := testRunStore(t)
+	archival := NewArchivalStore(store.db)
+	run, _ := store.Create("claude", "claude", "h", "")
+	edited, _ := archival.Add("Stu drinks tea", nil, nil)
+	store.LinkArchival(run.ID, edited.ID)
+	untouched, _ := archival.Add("Stu cycles", nil, nil)
+	store.LinkArchival(run.ID, untouched.ID)
+
+	content := "Stu drinks green tea"
+	if _, err := archival.Update(edited.ID, ArchivalPatch{Content: &content}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	res, err := store.Undo(run.ID)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if res.Facts != 1 || len(res.FactsSkipped) != 1 || res.FactsSkipped[0] != edited.ID {
+		t.Errorf("expected the edited fact skipped and the other deleted, got %+v", res)
+	}
+	if e, err := archival.GetByID(edited.ID); err != nil || e.Content != content {
+		t.Errorf("expected the edit kept, got %+v (%v)", e, err)
+	}
+	if _, err := archival.GetByID(untouched.ID); err == nil {
+		t.Error("expected the unedited fact deleted")
+	}
+}
+
+func TestDeleteRelation_KeepsSharedEntities(t *testing.T) {
+	graph := NewGraphStore(testRunStore(t).db)
+	id, _, _ := graph.EnsureRelation("A", "knows", "B", "")
+	graph.AddRelation("A", "knows", "C", "")
+
+	if err := graph.DeleteRelation(id); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	entities, _ := graph.ListEntities("")
+	if len(entities) != 2 || entities[0].Name != "A" || entities[1].Name != "C" {
+		t.Errorf("expected A and C to remain, got %+v", entities)
+	}
+}
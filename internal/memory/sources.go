@@ -0,0 +1,105 @@
+package memory
+
+import "fmt"
+
+// SourceChunk is one passage of a source file, as stored in archival
+// memory.
+type SourceChunk struct {
+	Content   string
+	Offset    int    // byte offset of Content in the file
+	Embedding []byte // float32 embedding, set by the caller before ApplySource
+}
+
+// SourceSync is the set of changes that brings the entries stored for a
+// source file in line with its current chunks. Chunks whose content is
+// already stored keep their entry, so re-syncing an edited file only adds,
+// embeds and deletes what changed.
+type SourceSync struct {
+	Source    string
+	Add       []*SourceChunk // chunks not stored yet
+	Move      map[int64]int  // unchanged entries whose chunk moved, to their new offset
+	Delete    []int64        // entries whose chunk is no longer in the file
+	Unchanged int            // entries kept as they are, including moved ones
+}
+
+// Changed reports whether applying the sync would change anything.
+func (s *SourceSync) Changed() bool {
+	return len(s.Add) > 0 || len(s.Move) > 0 || len(s.Delete) > 0
+}
+
+// BySource returns the entries loaded from source, in file order.
+func (s *ArchivalStore) BySource(source string) ([]*ArchivalEntry, error) {
+	entries, err := s.queryArchival(
+		`SELECT `+archivalColumns+` FROM archival a WHERE a.source = ? ORDER BY a.source_offset, a.id`, source,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("archival from %s: %w", source, err)
+	}
+	return entries, nil
+}
+
+// PlanSource compares a source file's current chunks with the entries
+// stored for it. Chunks are matched to entries by content; when a passage
+// appears more than once, each copy is matched to one entry.
+func (s *ArchivalStore) PlanSource(source string, chunks []*SourceChunk) (*SourceSync, error) {
+	existing, err := s.BySource(source)
+	if err != nil {
+		return nil, err
+	}
+	stored := map[string][]*ArchivalEntry{}
+	for _, e := range existing {
+		stored[e.Content] = append(stored[e.Content], e)
+	}
+
+	sync := &SourceSync{Source: source, Move: map[int64]int{}}
+	kept := map[int64]bool{}
+	for _, c := range chunks {
+		matches := stored[c.Content]
+		if len(matches) == 0 {
+			sync.Add = append(sync.Add, c)
+			continue
+		}
+		e := matches[0]
+		stored[c.Content] = matches[1:]
+		kept[e.ID] = true
+		sync.Unchanged++
+		if e.SourceOffset != c.Offset {
+			sync.Move[e.ID] = c.Offset
+		}
+	}
+	for _, e := range existing {
+		if !kept[e.ID] {
+			sync.Delete = append(sync.Delete, e.ID)
+		}
+	}
+	return sync, nil
+}
+
+// ApplySource applies a planned sync in one transaction, tagging added
+// chunks with tags.
+func (s *ArchivalStore) ApplySource(sync *SourceSync, tags []string) error {
+	return withTx(s.db, func(tx DBTX) error {
+		store := s.withDB(tx)
+		if err := store.Delete(sync.Delete...); err != nil {
+			return err
+		}
+		for id, offset := range sync.Move {
+			if _, err := tx.Exec(`UPDATE archival SET source_offset = ? WHERE id = ?`, offset, id); err != nil {
+				return fmt.Errorf("move archival %d: %w", id, err)
+			}
+		}
+		for _, c := range sync.Add {
+			_, err := store.Insert(NewArchival{
+				Content:      c.Content,
+				Tags:         tags,
+				Source:       sync.Source,
+				SourceOffset: c.Offset,
+				Embedding:    c.Embedding,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
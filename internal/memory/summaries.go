@@ -11,14 +11,15 @@ type Summary struct {
 	Level     int       `json:"level"`
 	Content   string    `json:"content"`
 	SourceIDs string    `json:"source_ids,omitempty"`
+	RunID     *int64    `json:"run_id,omitempty"` // ingest run that created the summary
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type SummaryStore struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewSummaryStore(db *sql.DB) *SummaryStore {
+func NewSummaryStore(db DBTX) *SummaryStore {
 	return &SummaryStore{db: db}
 }
 
@@ -37,8 +38,8 @@ func (s *SummaryStore) Add(level int, content, sourceIDs string) (*Summary, erro
 func (s *SummaryStore) GetByID(id int64) (*Summary, error) {
 	sm := &Summary{}
 	err := s.db.QueryRow(
-		`SELECT id, level, content, source_ids, created_at FROM conversation_summaries WHERE id = ?`, id,
-	).Scan(&sm.ID, &sm.Level, &sm.Content, &sm.SourceIDs, &sm.CreatedAt)
+		`SELECT id, level, content, source_ids, run_id, created_at FROM conversation_summaries WHERE id = ?`, id,
+	).Scan(&sm.ID, &sm.Level, &sm.Content, &sm.SourceIDs, &sm.RunID, &sm.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("get summary %d: %w", id, err)
 	}
@@ -50,19 +51,42 @@ func (s *SummaryStore) List(level int, limit int) ([]*Summary, error) {
 		limit = 20
 	}
 	rows, err := s.db.Query(
-		`SELECT id, level, content, source_ids, created_at FROM conversation_summaries
+		`SELECT id, level, content, source_ids, run_id, created_at FROM conversation_summaries
 		WHERE level = ? ORDER BY created_at DESC, id DESC LIMIT ?`,
 		level, limit,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list summaries: %w", err)
 	}
+	return scanSummaries(rows)
+}
+
+// ByRun returns the summaries created by an ingest run.
+func (s *SummaryStore) ByRun(runID int64) ([]*Summary, error) {
+	rows, err := s.db.Query(
+		`SELECT id, level, content, source_ids, run_id, created_at FROM conversation_summaries
+		WHERE run_id = ? ORDER BY id`,
+		runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("summaries for run %d: %w", runID, err)
+	}
+	return scanSummaries(rows)
+}
+
+// Delete removes a summary.
+func (s *SummaryStore) Delete(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM conversation_summaries WHERE id = ?`, id)
+	return err
+}
+
+func scanSummaries(rows *sql.Rows) ([]*Summary, error) {
 	defer rows.Close()
 
 	var summaries []*Summary
 	for rows.Next() {
 		sm := &Summary{}
-		if err := rows.Scan(&sm.ID, &sm.Level, &sm.Content, &sm.SourceIDs, &sm.CreatedAt); err != nil {
+		if err := rows.Scan(&sm.ID, &sm.Level, &sm.Content, &sm.SourceIDs, &sm.RunID, &sm.CreatedAt); err != nil {
 			return nil, err
 		}
 		summaries = append(summaries, sm)
@@ -0,0 +1,481 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagExists   = errors.New("tag already exists")
+)
+
+// TagCount is a tag and the number of archival entries carrying it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// TagFilter selects archival entries by tag. An entry matches when it has
+// every tag in All and, if Any is non-empty, at least one tag in Any, where
+// having a tag includes having any of its descendants. Tag names match
+// exactly, ignoring case.
+type TagFilter struct {
+	All []string
+	Any []string
+}
+
+// clause returns a condition on archival alias "a" implementing the filter,
+// or "" when it matches everything.
+func (f TagFilter) clause() (string, []any) {
+	var conds []string
+	var args []any
+	for _, t := range normalizeTags(f.All) {
+		conds = append(conds, `a.id IN (SELECT x.archival_id FROM archival_tags x JOIN tags t ON t.id = x.tag_id
+			WHERE `+tagMatch+`)`)
+		args = append(args, t, likePrefix(t))
+	}
+	if anyOf := normalizeTags(f.Any); len(anyOf) > 0 {
+		matches := make([]string, len(anyOf))
+		for i, t := range anyOf {
+			matches[i] = tagMatch
+			args = append(args, t, likePrefix(t))
+		}
+		conds = append(conds, `a.id IN (SELECT x.archival_id FROM archival_tags x JOIN tags t ON t.id = x.tag_id
+			WHERE `+strings.Join(matches, " OR ")+`)`)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// tagMatch matches tag alias "t" against a name or any of its descendants.
+// It takes the name and likePrefix(name) as arguments.
+const tagMatch = `(t.name = ? OR t.name LIKE ? ESCAPE '\')`
+
+// likePrefix returns a LIKE pattern matching the descendants of tag.
+func likePrefix(tag string) string {
+	return likeEscape(tag) + "/%"
+}
+
+// likeEscape escapes LIKE wildcards in s for use with ESCAPE '\'.
+func likeEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// normalizeTag trims a tag name and each of its path segments, dropping
+// empty segments: " work / contracts/ " becomes "work/contracts".
+func normalizeTag(tag string) string {
+	var segs []string
+	for _, seg := range strings.Split(tag, "/") {
+		if seg = strings.TrimSpace(seg); seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// normalizeTags normalizes tag names and drops empty and repeated ones,
+// keeping the first occurrence's position.
+func normalizeTags(tags []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = normalizeTag(t)
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+type tagRef struct {
+	id   int64
+	name string
+}
+
+// ensureTags creates any of the named tags that don't exist and returns them
+// all with their stored spelling.
+func ensureTags(db DBTX, names []string) ([]tagRef, error) {
+	refs := make([]tagRef, 0, len(names))
+	for _, name := range names {
+		if _, err := db.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, name); err != nil {
+			return nil, fmt.Errorf("create tag %q: %w", name, err)
+		}
+		var r tagRef
+		if err := db.QueryRow(`SELECT id, name FROM tags WHERE name = ?`, name).Scan(&r.id, &r.name); err != nil {
+			return nil, fmt.Errorf("get tag %q: %w", name, err)
+		}
+		refs = append(refs, r)
+	}
+	return refs, nil
+}
+
+// tagNames joins refs into the archival.tags form.
+func tagNames(refs []tagRef) string {
+	names := make([]string, len(refs))
+	for i, r := range refs {
+		names[i] = r.name
+	}
+	return strings.Join(names, ",")
+}
+
+// linkTags replaces an entry's tag links with refs, in order.
+func linkTags(db DBTX, archivalID int64, refs []tagRef) error {
+	if _, err := db.Exec(`DELETE FROM archival_tags WHERE archival_id = ?`, archivalID); err != nil {
+		return fmt.Errorf("unlink tags of %d: %w", archivalID, err)
+	}
+	for i, r := range refs {
+		if _, err := db.Exec(
+			`INSERT INTO archival_tags (archival_id, tag_id, position) VALUES (?, ?, ?)`,
+			archivalID, r.id, i,
+		); err != nil {
+			return fmt.Errorf("link tag %q to %d: %w", r.name, archivalID, err)
+		}
+	}
+	return nil
+}
+
+// pruneTags deletes tags no entry carries any more.
+func pruneTags(db DBTX) error {
+	if _, err := db.Exec(`DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM archival_tags)`); err != nil {
+		return fmt.Errorf("prune tags: %w", err)
+	}
+	return nil
+}
+
+// syncTagStrings rewrites archival.tags for the given entries from their
+// tag links.
+func syncTagStrings(db DBTX, ids []int64) error {
+	for _, id := range ids {
+		rows, err := db.Query(
+			`SELECT t.name FROM archival_tags x JOIN tags t ON t.id = x.tag_id
+			WHERE x.archival_id = ? ORDER BY x.position, t.name`, id,
+		)
+		if err != nil {
+			return fmt.Errorf("tags of %d: %w", id, err)
+		}
+		var names []string
+		for rows.Next() {
+			var n string
+			if err := rows.Scan(&n); err != nil {
+				rows.Close()
+				return err
+			}
+			names = append(names, n)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`UPDATE archival SET tags = ? WHERE id = ?`, strings.Join(names, ","), id); err != nil {
+			return fmt.Errorf("update tags of %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// TagStore manages tags, which live in the tags table and are linked to
+// archival entries through archival_tags. Tag names are case-insensitive:
+// "Work" and "work" are one tag, spelled as it was first stored.
+// archival.tags keeps a comma-joined copy of each entry's tags for display
+// and full-text search; the stores keep it in step with the tables.
+//
+// Tags form a hierarchy on "/": "work/contracts/ir35" is a descendant of
+// "work/contracts" and "work", and filtering by a tag includes its
+// descendants. Parents need not exist as tags themselves.
+type TagStore struct {
+	db DBTX
+}
+
+func NewTagStore(db DBTX) *TagStore {
+	return &TagStore{db: db}
+}
+
+// List returns every tag in use with its entry count, most used first.
+func (s *TagStore) List() ([]TagCount, error) {
+	rows, err := s.db.Query(
+		`SELECT t.name, COUNT(*) FROM tags t JOIN archival_tags x ON x.tag_id = t.id
+		GROUP BY t.id ORDER BY COUNT(*) DESC, t.name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []TagCount
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Name, &tc.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tc)
+	}
+	return tags, rows.Err()
+}
+
+// TagNode is a tag in the hierarchy, which may exist only as the parent of
+// other tags.
+type TagNode struct {
+	Name     string     `json:"name"`  // last path segment
+	Path     string     `json:"path"`  // full tag name
+	Count    int        `json:"count"` // entries tagged exactly Path
+	Total    int        `json:"total"` // entries tagged Path or a descendant
+	Children []*TagNode `json:"children,omitempty"`
+}
+
+// Tree returns the tags in use as a hierarchy, sorted by name at each level.
+func (s *TagStore) Tree() ([]*TagNode, error) {
+	rows, err := s.db.Query(`SELECT t.name, x.archival_id FROM tags t JOIN archival_tags x ON x.tag_id = t.id`)
+	if err != nil {
+		return nil, fmt.Errorf("tag tree: %w", err)
+	}
+	defer rows.Close()
+
+	root := &TagNode{}
+	nodes := map[string]*TagNode{}
+	entries := map[*TagNode]map[int64]bool{}
+	for rows.Next() {
+		var name string
+		var aid int64
+		if err := rows.Scan(&name, &aid); err != nil {
+			return nil, err
+		}
+		parent := root
+		segs := strings.Split(name, "/")
+		for i, seg := range segs {
+			path := strings.Join(segs[:i+1], "/")
+			n, ok := nodes[strings.ToLower(path)]
+			if !ok {
+				n = &TagNode{Name: seg, Path: path}
+				nodes[strings.ToLower(path)] = n
+				entries[n] = map[int64]bool{}
+				parent.Children = append(parent.Children, n)
+			}
+			entries[n][aid] = true
+			parent = n
+		}
+		parent.Count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for n, ids := range entries {
+		n.Total = len(ids)
+	}
+	var sortTree func([]*TagNode)
+	sortTree = func(level []*TagNode) {
+		sort.Slice(level, func(i, j int) bool { return strings.ToLower(level[i].Name) < strings.ToLower(level[j].Name) })
+		for _, n := range level {
+			sortTree(n.Children)
+		}
+	}
+	sortTree(root.Children)
+	return root.Children, nil
+}
+
+// subtree returns the named tag and its descendants, by ID. It fails with
+// ErrTagNotFound when there are none.
+func subtree(db DBTX, name string) (map[int64]string, error) {
+	name = normalizeTag(name)
+	rows, err := db.Query(`SELECT t.id, t.name FROM tags t WHERE `+tagMatch, name, likePrefix(name))
+	if err != nil {
+		return nil, fmt.Errorf("find tag %q: %w", name, err)
+	}
+	defer rows.Close()
+	tags := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var n string
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		tags[id] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("%q: %w", name, ErrTagNotFound)
+	}
+	return tags, nil
+}
+
+// taggedEntries returns the IDs of the entries carrying a tag.
+func taggedEntries(db DBTX, tagID int64) ([]int64, error) {
+	rows, err := db.Query(`SELECT archival_id FROM archival_tags WHERE tag_id = ? ORDER BY archival_id`, tagID)
+	if err != nil {
+		return nil, fmt.Errorf("entries tagged %d: %w", tagID, err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var aid int64
+		if err := rows.Scan(&aid); err != nil {
+			return nil, err
+		}
+		ids = append(ids, aid)
+	}
+	return ids, rows.Err()
+}
+
+// keys returns a set's members.
+func keys(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Rename changes a tag's name, and the prefix of its descendants', on every
+// entry carrying them and returns how many entries that was. Renaming onto
+// another existing tag fails with ErrTagExists; use Merge for that.
+func (s *TagStore) Rename(from, to string) (int, error) {
+	from, to = normalizeTag(from), normalizeTag(to)
+	if to == "" || strings.Contains(to, ",") {
+		return 0, fmt.Errorf("invalid tag name %q", to)
+	}
+	var n int
+	err := withTx(s.db, func(tx DBTX) error {
+		tags, err := subtree(tx, from)
+		if err != nil {
+			return err
+		}
+		renames := map[int64]string{}
+		for id, name := range tags {
+			renames[id] = to + name[len(from):]
+		}
+
+		// A name held by a tag being renamed itself is free to take, as
+		// when renaming "a" to "a/b".
+		for id, name := range renames {
+			var other int64
+			err := tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, name).Scan(&other)
+			if _, renamed := renames[other]; err == nil && other != id && !renamed {
+				return fmt.Errorf("%q: %w — merge into it instead", name, ErrTagExists)
+			}
+		}
+		// Move every tag out of the way first so no rename collides with a
+		// name that is about to be freed. Real tag names can't contain a
+		// comma, so the placeholders can't clash with them.
+		for id := range renames {
+			if _, err := tx.Exec(`UPDATE tags SET name = ? WHERE id = ?`, fmt.Sprintf(",renaming %d", id), id); err != nil {
+				return fmt.Errorf("rename tag: %w", err)
+			}
+		}
+		affected := map[int64]bool{}
+		for id, name := range renames {
+			if _, err := tx.Exec(`UPDATE tags SET name = ? WHERE id = ?`, name, id); err != nil {
+				return fmt.Errorf("rename tag: %w", err)
+			}
+			aids, err := taggedEntries(tx, id)
+			if err != nil {
+				return err
+			}
+			for _, aid := range aids {
+				affected[aid] = true
+			}
+		}
+		n = len(affected)
+		return syncTagStrings(tx, keys(affected))
+	})
+	return n, err
+}
+
+// Merge moves every entry tagged with one of sources onto the target tag
+// (created if needed) and deletes the sources. Descendants of a source move
+// to the same place under the target: merging "a" into "b" moves "a/x" to
+// "b/x". It returns how many entries were retagged.
+func (s *TagStore) Merge(target string, sources ...string) (int, error) {
+	target = normalizeTag(target)
+	if target == "" || strings.Contains(target, ",") {
+		return 0, fmt.Errorf("invalid tag name %q", target)
+	}
+	affected := map[int64]bool{}
+	err := withTx(s.db, func(tx DBTX) error {
+		for _, src := range sources {
+			src = normalizeTag(src)
+			if strings.EqualFold(src, target) {
+				continue
+			}
+			if len(target) > len(src) && strings.EqualFold(target[:len(src)+1], src+"/") {
+				return fmt.Errorf("can't merge %q into its own descendant %q", src, target)
+			}
+			tags, err := subtree(tx, src)
+			if err != nil {
+				return err
+			}
+			for id, name := range tags {
+				refs, err := ensureTags(tx, []string{target + name[len(src):]})
+				if err != nil {
+					return err
+				}
+				if err := mergeTag(tx, id, refs[0].id, affected); err != nil {
+					return fmt.Errorf("merge tag %q: %w", name, err)
+				}
+			}
+		}
+		if err := syncTagStrings(tx, keys(affected)); err != nil {
+			return err
+		}
+		return pruneTags(tx)
+	})
+	return len(affected), err
+}
+
+// mergeTag moves the entries carrying tag from onto tag into, deletes from
+// and adds the entries it moved to affected.
+func mergeTag(db DBTX, from, into int64, affected map[int64]bool) error {
+	ids, err := taggedEntries(db, from)
+	if err != nil {
+		return err
+	}
+	// Entries that already carry the target keep its position.
+	if _, err := db.Exec(
+		`INSERT OR IGNORE INTO archival_tags (archival_id, tag_id, position)
+		SELECT archival_id, ?, position FROM archival_tags WHERE tag_id = ?`,
+		into, from,
+	); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM tags WHERE id = ?`, from); err != nil {
+		return err
+	}
+	for _, aid := range ids {
+		affected[aid] = true
+	}
+	return nil
+}
+
+// Delete removes a tag and its descendants from every entry carrying them,
+// leaving the entries themselves, and returns how many entries lost a tag.
+func (s *TagStore) Delete(name string) (int, error) {
+	var n int
+	err := withTx(s.db, func(tx DBTX) error {
+		tags, err := subtree(tx, name)
+		if err != nil {
+			return err
+		}
+		affected := map[int64]bool{}
+		for id, tag := range tags {
+			ids, err := taggedEntries(tx, id)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM tags WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("delete tag %q: %w", tag, err)
+			}
+			for _, aid := range ids {
+				affected[aid] = true
+			}
+		}
+		n = len(affected)
+		return syncTagStrings(tx, keys(affected))
+	})
+	return n, err
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTagStore_ListRenameMergeDelete(t *testing.T) {
+	archival := testArchivalStore(t)
+	tags := NewTagStore(archival.db)
+	a, _ := archival.Add("a", []string{"work", "go"}, nil)
+	b, _ := archival.Add("b", []string{"job"}, nil)
+	c, _ := archival.Add("c", []string{"work", "job"}, nil)
+
+	list, err := tags.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 3 || list[0].Count != 2 || list[2] != (TagCount{"go", 1}) {
+		t.Fatalf("unexpected tag counts %v", list)
+	}
+
+	if _, err := tags.Rename("go", "work"); !errors.Is(err, ErrTagExists) {
+		t.Errorf("expected ErrTagExists renaming onto an existing tag, got %v", err)
+	}
+	if n, err := tags.Rename("go", "golang"); err != nil || n != 1 {
+		t.Fatalf("rename: %d, %v", n, err)
+	}
+	if e, _ := archival.GetByID(a.ID); e.Tags != "work,golang" {
+		t.Errorf("expected renamed tag on entry, got %q", e.Tags)
+	}
+
+	if n, err := tags.Merge("work", "job"); err != nil || n != 2 {
+		t.Fatalf("merge: %d, %v", n, err)
+	}
+	if e, _ := archival.GetByID(b.ID); e.Tags != "work" {
+		t.Errorf("expected job merged into work, got %q", e.Tags)
+	}
+	if e, _ := archival.GetByID(c.ID); e.Tags != "work" {
+		t.Errorf("expected duplicate tag collapsed, got %q", e.Tags)
+	}
+
+	if n, err := tags.Delete("work"); err != nil || n != 3 {
+		t.Fatalf("delete: %d, %v", n, err)
+	}
+	if _, err := tags.Delete("work"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("expected ErrTagNotFound, got %v", err)
+	}
+	if list, _ := tags.List(); len(list) != 1 || list[0].Name != "golang" {
+		t.Errorf("expected only golang left, got %v", list)
+	}
+}
+
+func TestTags_Hierarchy(t *testing.T) {
+	archival := testArchivalStore(t)
+	tags := NewTagStore(archival.db)
+	archival.Add("ir35", []string{" work / contracts/ir35 "}, nil)
+	archival.Add("rates", []string{"work/contracts", "work/rates"}, nil)
+	archival.Add("gym", []string{"personal/health"}, nil)
+	archival.Add("workshop", []string{"workshop"}, nil)
+
+	entries, err := archival.List("work", 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected work to include its descendants only, got %d entries", len(entries))
+	}
+	if e, _ := archival.List("work/contracts/ir35", 10); len(e) != 1 || e[0].Tags != "work/contracts/ir35" {
+		t.Errorf("expected normalized leaf tag, got %v", e)
+	}
+
+	tree, err := tags.Tree()
+	if err != nil {
+		t.Fatalf("tree: %v", err)
+	}
+	if len(tree) != 3 || tree[1].Path != "work" || tree[1].Total != 2 || tree[1].Count != 0 {
+		t.Fatalf("unexpected roots %+v", tree)
+	}
+	contracts := tree[1].Children[0]
+	if contracts.Path != "work/contracts" || contracts.Count != 1 || contracts.Total != 2 || len(contracts.Children) != 1 {
+		t.Errorf("unexpected work/contracts node %+v", contracts)
+	}
+
+	if n, err := tags.Rename("work", "job"); err != nil || n != 2 {
+		t.Fatalf("rename: %d, %v", n, err)
+	}
+	if e, _ := archival.List("job/contracts/ir35", 10); len(e) != 1 {
+		t.Error("expected descendants to be renamed with their parent")
+	}
+	if e, _ := archival.List("workshop", 10); len(e) != 1 {
+		t.Error("expected workshop to be left alone")
+	}
+}
+
+func TestTags_MergeAndDeleteIncludeDescendants(t *testing.T) {
+	archival := testArchivalStore(t)
+	tags := NewTagStore(archival.db)
+	ir35, _ := archival.Add("ir35", []string{"work/contracts/ir35"}, nil)
+	rates, _ := archival.Add("rates", []string{"work/rates", "job/rates"}, nil)
+	archival.Add("gym", []string{"personal/health"}, nil)
+
+	if _, err := tags.Merge("work/contracts", "work"); err == nil {
+		t.Error("expected merging a tag into its own descendant to fail")
+	}
+	if n, err := tags.Merge("job", "work"); err != nil || n != 2 {
+		t.Fatalf("merge: %d, %v", n, err)
+	}
+	if e, _ := archival.GetByID(ir35.ID); e.Tags != "job/contracts/ir35" {
+		t.Errorf("expected descendant moved under the target, got %q", e.Tags)
+	}
+	if e, _ := archival.GetByID(rates.ID); e.Tags != "job/rates" {
+		t.Errorf("expected descendant collapsed into the existing one, got %q", e.Tags)
+	}
+	if e, _ := archival.List("work", 10); len(e) != 0 {
+		t.Errorf("expected nothing left under work, got %d entries", len(e))
+	}
+
+	archival.Add("jobs", []string{"job"}, nil)
+	if _, err := tags.Rename("job/contracts/ir35", "job/rates"); !errors.Is(err, ErrTagExists) {
+		t.Errorf("expected ErrTagExists renaming onto a tag outside the renamed set, got %v", err)
+	}
+	// "job/rates" is one of the tags being renamed, so it doesn't block
+	// renaming "job" onto it.
+	if n, err := tags.Rename("job", "job/rates"); err != nil || n != 3 {
+		t.Fatalf("rename into a descendant: %d, %v", n, err)
+	}
+	if e, _ := archival.GetByID(rates.ID); e.Tags != "job/rates/rates" {
+		t.Errorf("unexpected tags after rename %q", e.Tags)
+	}
+
+	if n, err := tags.Delete("job"); err != nil || n != 3 {
+		t.Fatalf("delete: %d, %v", n, err)
+	}
+	if list, _ := tags.List(); len(list) != 1 || list[0].Name != "personal/health" {
+		t.Errorf("expected descendants deleted with their parent, got %v", list)
+	}
+}
@@ -1,17 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/stukennedy/botmem/internal/chunk"
 	"github.com/stukennedy/botmem/internal/config"
 	botmemctx "github.com/stukennedy/botmem/internal/context"
 	"github.com/stukennedy/botmem/internal/db"
 	"github.com/stukennedy/botmem/internal/embeddings"
 	"github.com/stukennedy/botmem/internal/ingest"
+	"github.com/stukennedy/botmem/internal/llm"
 	"github.com/stukennedy/botmem/internal/memory"
 
 	"github.com/spf13/cobra"
@@ -26,7 +37,7 @@ func main() {
 	}
 	root.PersistentFlags().StringVar(&dbPath, "db", "", "database path (default: ~/.botmem/botmem.db)")
 
-	root.AddCommand(initCmd(), blockCmd(), archiveCmd(), graphCmd(), summaryCmd(), contextCmd(), ingestCmd())
+	root.AddCommand(initCmd(), blockCmd(), archiveCmd(), tagCmd(), graphCmd(), summaryCmd(), contextCmd(), ingestCmd(), forgetCmd(), reindexCmd(), embeddingsCmd(), dbCmd())
 
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
@@ -152,61 +163,1446 @@ func blockCmd() *cobra.Command {
 func archiveCmd() *cobra.Command {
 	cmd := &cobra.Command{Use: "archive", Short: "Manage archival memory"}
 
+	add := &cobra.Command{
+		Use:   "add <text> [--tags tag1,tag2] [--source src] [--meta key=value]",
+		Short: "Add an archival entry",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tagsFlag, _ := cmd.Flags().GetString("tags")
+			var tags []string
+			if tagsFlag != "" {
+				tags = strings.Split(tagsFlag, ",")
+			}
+			source, _ := cmd.Flags().GetString("source")
+			meta, err := metadataFlags(cmd, nil)
+			if err != nil {
+				return err
+			}
+			var importance *float64
+			if cmd.Flags().Changed("importance") {
+				v, _ := cmd.Flags().GetFloat64("importance")
+				importance = &v
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			store, emb, err := embedArchival(database, args[0])
+			if err != nil {
+				return err
+			}
+			e, err := store.Insert(memory.NewArchival{
+				Content:    args[0],
+				Tags:       tags,
+				Source:     source,
+				Metadata:   meta,
+				Importance: importance,
+				Embedding:  emb,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Added archival entry (id=%d)\n", e.ID)
+			return nil
+		},
+	}
+	add.Flags().String("tags", "", "comma-separated tags")
+	add.Flags().String("source", "", "where the fact came from: a URL, file path, conversation ID...")
+	add.Flags().Float64("importance", memory.DefaultImportance, "how much the fact matters, from 0 to 1 (1: never forget)")
+	addMetadataFlags(add)
+	cmd.AddCommand(add)
+
+	search := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search archival memory (hybrid when embeddings are enabled, else full-text)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			semantic, _ := cmd.Flags().GetBool("semantic")
+			keyword, _ := cmd.Flags().GetBool("keyword")
+			asJSON, _ := cmd.Flags().GetBool("json")
+			if semantic && keyword {
+				return fmt.Errorf("--semantic and --keyword are mutually exclusive")
+			}
+			query := ftsQuery(cmd, args[0])
+			filter, err := archiveFilter(cmd)
+			if err != nil {
+				return err
+			}
+
+			// Config is optional for keyword search; without embeddings we
+			// fall back to FTS5 only.
+			var embedProv embeddings.Provider
+			var weights memory.HybridWeights
+			var columns memory.ColumnWeights
+			var recency memory.Recency
+			halfLife := ""
+			cfg, cfgErr := config.Load("")
+			if cfgErr == nil {
+				if embedProv, cfgErr = newEmbedProvider(cfg.Embeddings); cfgErr != nil {
+					return cfgErr
+				}
+				weights = memory.HybridWeights{
+					Lexical:  cfg.Search.LexicalWeight,
+					Semantic: cfg.Search.SemanticWeight,
+					K:        cfg.Search.RRFK,
+				}
+				columns = memory.ColumnWeights{Content: cfg.Search.ContentWeight, Tags: cfg.Search.TagsWeight}
+				recency.Weight = cfg.Search.RecencyWeight
+				halfLife = cfg.Search.RecencyHalfLife
+			}
+			if cmd.Flags().Changed("content-weight") {
+				columns.Content, _ = cmd.Flags().GetFloat64("content-weight")
+			}
+			if cmd.Flags().Changed("tags-weight") {
+				columns.Tags, _ = cmd.Flags().GetFloat64("tags-weight")
+			}
+			if cmd.Flags().Changed("lexical-weight") {
+				weights.Lexical, _ = cmd.Flags().GetFloat64("lexical-weight")
+			}
+			if cmd.Flags().Changed("semantic-weight") {
+				weights.Semantic, _ = cmd.Flags().GetFloat64("semantic-weight")
+			}
+			if cmd.Flags().Changed("recency") {
+				recency.Weight, _ = cmd.Flags().GetFloat64("recency")
+			}
+			if cmd.Flags().Changed("half-life") {
+				halfLife, _ = cmd.Flags().GetString("half-life")
+			}
+			if recency.Weight < 0 || recency.Weight > 1 {
+				return fmt.Errorf("recency weight must be between 0 and 1")
+			}
+			if halfLife != "" {
+				if recency.HalfLife, err = parseAge(halfLife); err != nil {
+					return err
+				}
+			}
+			if semantic && embedProv == nil {
+				if cfgErr != nil {
+					return cfgErr
+				}
+				return fmt.Errorf("embeddings are not enabled — run 'botmem init' to enable them")
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+			store := memory.NewArchivalStore(database).
+				WithFilter(filter).
+				WithRecency(recency).
+				WithColumnWeights(columns)
+
+			var vec []float32
+			if !keyword && embedProv != nil {
+				embedProv = cacheEmbeddings(embedProv, database)
+				store = store.WithEmbeddingModel(embedProv.ModelID())
+				vec, err = embedProv.Embed(args[0])
+				if err != nil {
+					if semantic {
+						return fmt.Errorf("embed query: %w", err)
+					}
+					fmt.Fprintf(os.Stderr, "warning: embed query failed, using keyword search: %v\n", err)
+				}
+			}
+
+			var results []*memory.SearchResult
+			switch {
+			case vec == nil:
+				results, err = store.Search(query, 10)
+			case semantic:
+				results, err = store.SearchSemantic(vec, 10)
+			default:
+				results, err = store.SearchHybrid(query, vec, 10, weights)
+			}
+			if err != nil {
+				return queryError(err)
+			}
+
+			// No words matched exactly: retry the text with typos and partial
+			// words allowed. Hybrid search nearly always returns some nearest
+			// embeddings, so only keyword matches count here.
+			raw, _ := cmd.Flags().GetBool("raw")
+			exact, _ := cmd.Flags().GetBool("exact")
+			if keywordMatches(results, vec != nil) == 0 && !semantic && !raw && !exact {
+				fuzzy, err := store.FuzzyQuery(args[0])
+				if err != nil {
+					return err
+				}
+				if fuzzy != "" {
+					var approx []*memory.SearchResult
+					if vec == nil {
+						approx, err = store.Search(fuzzy, 10)
+					} else {
+						approx, err = store.SearchHybrid(fuzzy, vec, 10, weights)
+					}
+					if err != nil {
+						return err
+					}
+					if keywordMatches(approx, vec != nil) > 0 {
+						results = approx
+						fmt.Fprintln(os.Stderr, "No exact matches; showing approximate ones.")
+					}
+				}
+			}
+
+			if asJSON {
+				return printJSON(results)
+			}
+			full, _ := cmd.Flags().GetBool("full")
+			for _, r := range results {
+				text := r.Content
+				if r.Snippet != "" && !full {
+					text = r.Snippet
+				}
+				fmt.Printf("[%d] %s (tags: %s, score: %.3g)%s%s\n", r.ID, text, r.Tags, r.Score, metaLabel(r.ArchivalEntry), runLabel(r.RunID))
+			}
+			if len(results) == 0 {
+				fmt.Println("No results.")
+			}
+			return nil
+		},
+	}
+	search.Flags().Bool("semantic", false, "rank by embedding similarity only (requires embeddings)")
+	search.Flags().Bool("keyword", false, "rank by full-text (FTS5) match only")
+	search.Flags().Bool("json", false, "output results as JSON, including per-result scores")
+	search.Flags().Bool("raw", false, "pass the query to FTS5 as is, with its operators (AND, OR, NOT, \"phrases\", prefix*)")
+	search.Flags().Float64("lexical-weight", 1, "hybrid: weight of the full-text ranking")
+	search.Flags().Float64("semantic-weight", 1, "hybrid: weight of the embedding ranking")
+	search.Flags().Float64("content-weight", 1, "full-text: BM25 weight of matches in entry content")
+	search.Flags().Float64("tags-weight", 1, "full-text: BM25 weight of matches in entry tags")
+	search.Flags().Bool("full", false, "print whole entries instead of highlighted snippets of the match")
+	search.Flags().Bool("exact", false, "don't fall back to approximate matches when nothing matches exactly")
+	search.Flags().Float64("recency", 0, "weight (0-1) of entry age in the ranking, so recent facts outrank equally relevant old ones")
+	search.Flags().String("half-life", "", "age at which the recency factor halves (default 30d)")
+	addFilterFlags(search)
+	cmd.AddCommand(search)
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List archival entries",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			filter, err := archiveFilter(cmd)
+			if err != nil {
+				return err
+			}
+			entries, err := memory.NewArchivalStore(database).WithFilter(filter).List("", 50)
+			if err != nil {
+				return err
+			}
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				return printJSON(entries)
+			}
+			for _, e := range entries {
+				fmt.Printf("[%d] %s (tags: %s)%s%s\n", e.ID, truncate(e.Content, 80), e.Tags, metaLabel(e), runLabel(e.RunID))
+			}
+			return nil
+		},
+	}
+	list.Flags().Bool("json", false, "output entries as JSON")
+	addFilterFlags(list)
+	cmd.AddCommand(list)
+
+	get := &cobra.Command{
+		Use:   "get <id>",
+		Short: "Show an archival entry",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			e, err := memory.NewArchivalStore(database).GetByID(id)
+			if err != nil {
+				return err
+			}
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				return printJSON(e)
+			}
+			fmt.Printf("[%d] %s%s\n", e.ID, e.CreatedAt.Format("2006-01-02 15:04"), runLabel(e.RunID))
+			fmt.Printf("tags: %s\n", e.Tags)
+			if e.Source != "" {
+				fmt.Printf("source: %s", e.Source)
+				if e.SourceOffset > 0 {
+					fmt.Printf(" (offset %d)", e.SourceOffset)
+				}
+				fmt.Println()
+			}
+			for _, k := range e.Metadata.Keys() {
+				fmt.Printf("%s: %s\n", k, memory.MetaValueString(e.Metadata[k]))
+			}
+			fmt.Printf("importance: %.2g\n", e.Importance)
+			if e.LastAccessedAt != nil {
+				fmt.Printf("accessed: %d times, last %s\n", e.AccessCount, e.LastAccessedAt.Local().Format("2006-01-02 15:04"))
+			} else {
+				fmt.Println("accessed: never")
+			}
+			if e.ArchivedAt != nil {
+				fmt.Printf("archived: %s (botmem forget restore %d)\n", e.ArchivedAt.Local().Format("2006-01-02 15:04"), e.ID)
+			}
+			if e.Embedding != nil {
+				model := e.EmbeddingModel
+				if model == "" {
+					model = "unknown model"
+				}
+				fmt.Printf("embedding: %s, %d dimensions\n", model, e.EmbeddingDim)
+			} else {
+				fmt.Println("embedding: none")
+			}
+			fmt.Printf("\n%s\n", e.Content)
+			return nil
+		},
+	}
+	get.Flags().Bool("json", false, "output as JSON")
+	cmd.AddCommand(get)
+
+	edit := &cobra.Command{
+		Use:   "edit <id> [text] [--tags tag1,tag2] [--source src] [--meta key=value]",
+		Short: "Replace an archival entry's content, re-embedding it when embeddings are enabled, or its tags, source or metadata",
+		Long: `Replace an archival entry's content, re-embedding it when embeddings are
+enabled. --tags replaces its tags and --source its source. --meta key=value
+sets a metadata field, leaving the others, and --meta key= removes one;
+--metadata replaces the whole object.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			e, err := memory.NewArchivalStore(database).GetByID(id)
+			if err != nil {
+				return err
+			}
+			var patch memory.ArchivalPatch
+			store := memory.NewArchivalStore(database)
+			if len(args) == 2 {
+				var emb []byte
+				if store, emb, err = embedArchival(database, args[1]); err != nil {
+					return err
+				}
+				patch.Content, patch.Embedding = &args[1], emb
+			}
+			if cmd.Flags().Changed("tags") {
+				tagsFlag, _ := cmd.Flags().GetString("tags")
+				patch.Tags = append([]string{}, strings.Split(tagsFlag, ",")...)
+			}
+			if cmd.Flags().Changed("source") {
+				source, _ := cmd.Flags().GetString("source")
+				patch.Source = &source
+			}
+			if cmd.Flags().Changed("meta") || cmd.Flags().Changed("metadata") || cmd.Flags().Changed("author") {
+				if patch.Metadata, err = metadataFlags(cmd, e.Metadata); err != nil {
+					return err
+				}
+				if patch.Metadata == nil {
+					patch.Metadata = memory.Metadata{}
+				}
+			}
+			if cmd.Flags().Changed("importance") {
+				importance, _ := cmd.Flags().GetFloat64("importance")
+				patch.Importance = &importance
+			}
+			if patch.Content == nil && patch.Tags == nil && patch.Source == nil && patch.Metadata == nil && patch.Importance == nil {
+				return fmt.Errorf("give the new text, or --tags, --source, --meta, --metadata or --importance")
+			}
+			if _, err := store.Update(id, patch); err != nil {
+				return err
+			}
+			fmt.Printf("Updated archival entry (id=%d)\n", id)
+			return nil
+		},
+	}
+	edit.Flags().String("tags", "", "replace the entry's tags (comma-separated)")
+	edit.Flags().String("source", "", "replace the entry's source (\"\" removes it)")
+	edit.Flags().Float64("importance", memory.DefaultImportance, "set how much the entry matters, from 0 to 1 (1: never forget)")
+	addMetadataFlags(edit)
+	cmd.AddCommand(edit)
+
+	retag := &cobra.Command{
+		Use:   "retag <id> [tag1,tag2]",
+		Short: "Replace an archival entry's tags, or add and remove some with --add and --remove",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+			add, _ := cmd.Flags().GetStringSlice("add")
+			remove, _ := cmd.Flags().GetStringSlice("remove")
+			if len(args) == 1 && len(add) == 0 && len(remove) == 0 {
+				return fmt.Errorf("give the new tags, or --add or --remove")
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			store := memory.NewArchivalStore(database)
+			e, err := store.GetByID(id)
+			if err != nil {
+				return err
+			}
+			current := e.Tags
+			if len(args) == 2 {
+				current = args[1]
+			}
+			tags := []string{}
+			for _, t := range strings.Split(current, ",") {
+				if !containsFold(remove, strings.TrimSpace(t)) {
+					tags = append(tags, t)
+				}
+			}
+			tags = append(tags, add...)
+
+			updated, err := store.Update(id, memory.ArchivalPatch{Tags: tags})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("[%d] tags: %s\n", updated.ID, updated.Tags)
+			return nil
+		},
+	}
+	retag.Flags().StringSlice("add", nil, "tags to add")
+	retag.Flags().StringSlice("remove", nil, "tags to remove")
+	cmd.AddCommand(retag)
+
+	del := &cobra.Command{
+		Use:   "delete [id...]",
+		Short: "Delete archival entries by ID, or every entry matching --tag, --query or a date range",
+		Long: `Delete archival entries. Give their IDs, or select entries with any
+combination of --tag, --any-tag, --query, --since and --until; a bulk delete
+lists how many entries match and asks for confirmation unless --yes is given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := archiveFilter(cmd)
+			if err != nil {
+				return err
+			}
+			query, _ := cmd.Flags().GetString("query")
+			if query != "" {
+				if query = ftsQuery(cmd, query); query == "" {
+					return fmt.Errorf("--query has no words to search for")
+				}
+			}
+			bulk := query != "" || len(filter.Tags.All) > 0 || len(filter.Tags.Any) > 0 ||
+				!filter.Since.IsZero() || !filter.Until.IsZero() || len(filter.Where) > 0 || filter.Archived
+			if bulk && len(args) > 0 {
+				return fmt.Errorf("give entry IDs or filters, not both")
+			}
+			if !bulk && len(args) == 0 {
+				return fmt.Errorf("give entry IDs, or select entries with --tag, --any-tag, --where, --query, --since, --until or --archived")
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+			store := memory.NewArchivalStore(database)
+
+			var ids []int64
+			if !bulk {
+				for _, arg := range args {
+					id, err := parseID(arg)
+					if err != nil {
+						return err
+					}
+					if _, err := store.GetByID(id); err != nil {
+						return err
+					}
+					ids = append(ids, id)
+				}
+			} else {
+				entries, err := store.WithFilter(filter).Select(query)
+				if err != nil {
+					return queryError(err)
+				}
+				if len(entries) == 0 {
+					fmt.Println("No matching entries.")
+					return nil
+				}
+				for i, e := range entries {
+					if i < 10 {
+						fmt.Printf("[%d] %s (tags: %s)\n", e.ID, truncate(e.Content, 80), e.Tags)
+					}
+					ids = append(ids, e.ID)
+				}
+				if len(entries) > 10 {
+					fmt.Printf("... and %d more\n", len(entries)-10)
+				}
+				if yes, _ := cmd.Flags().GetBool("yes"); !yes && !confirm(fmt.Sprintf("Delete %d entries?", len(ids))) {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			if err := store.Delete(ids...); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %d archival entries.\n", len(ids))
+			return nil
+		},
+	}
+	addFilterFlags(del)
+	del.Flags().String("query", "", "only entries containing every word of this text")
+	del.Flags().Bool("raw", false, "treat --query as FTS5 syntax rather than plain text")
+	del.Flags().BoolP("yes", "y", false, "delete without asking for confirmation")
+	cmd.AddCommand(del)
+
+	addFile := &cobra.Command{
+		Use:   "add-file <path|glob|dir>...",
+		Short: "Load markdown and text files into archival memory in chunks, re-syncing ones loaded before",
+		Long: `Split files into passages of about --chunk-size bytes, breaking at markdown
+headings and between paragraphs, and store each one as an archival entry that
+records its source file and offset. Directories are searched for markdown and
+.txt files.
+
+Running add-file again on a file re-syncs it: passages that are unchanged are
+kept as they are (and not re-embedded), new ones are added and ones no longer
+in the file are deleted.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := documentFiles(args)
+			if err != nil {
+				return err
+			}
+			size, _ := cmd.Flags().GetInt("chunk-size")
+			var tags []string
+			if tagsFlag, _ := cmd.Flags().GetString("tags"); tagsFlag != "" {
+				tags = strings.Split(tagsFlag, ",")
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+			store, embedProv, err := archivalEmbedder(database)
+			if err != nil {
+				return err
+			}
+
+			for _, path := range files {
+				chunks, err := chunkFile(path, size)
+				if err != nil {
+					return err
+				}
+				source, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				plan, err := store.PlanSource(source, chunks)
+				if err != nil {
+					return err
+				}
+				if embedProv != nil {
+					for start := 0; start < len(plan.Add); start += 64 {
+						batch := plan.Add[start:min(start+64, len(plan.Add))]
+						texts := make([]string, len(batch))
+						for i, c := range batch {
+							texts[i] = c.Content
+						}
+						vecs, err := embedProv.BatchEmbed(texts)
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "warning: embedding %s failed, storing without embeddings: %v\n", path, err)
+							break
+						}
+						for i, c := range batch {
+							c.Embedding = embeddings.SerializeEmbedding(vecs[i])
+						}
+					}
+				}
+				if err := store.ApplySource(plan, tags); err != nil {
+					return err
+				}
+				fmt.Printf("%s: %d added, %d removed, %d unchanged\n", path, len(plan.Add), len(plan.Delete), plan.Unchanged)
+			}
+			return nil
+		},
+	}
+	addFile.Flags().String("tags", "", "comma-separated tags for the new chunks")
+	addFile.Flags().Int("chunk-size", chunk.DefaultSize, "longest chunk, in bytes")
+	cmd.AddCommand(addFile)
+
+	return cmd
+}
+
+// embedArchival returns an archival store set up to write embeddings as
+// configured, along with text's embedding. A missing config, or embeddings
+// that are disabled or fail, yield a nil embedding so the entry is still
+// stored.
+func embedArchival(database *sql.DB, text string) (*memory.ArchivalStore, []byte, error) {
+	store, embedProv, err := archivalEmbedder(database)
+	if err != nil || embedProv == nil {
+		return store, nil, err
+	}
+	vec, err := embedProv.Embed(text)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: embedding failed, storing without one: %v\n", err)
+		return store, nil, nil
+	}
+	return store, embeddings.SerializeEmbedding(vec), nil
+}
+
+// archivalEmbedder returns an archival store set up to write embeddings as
+// configured, and the cached provider to make them with. The provider is
+// nil when there is no config or embeddings are disabled or misconfigured.
+func archivalEmbedder(database *sql.DB) (*memory.ArchivalStore, embeddings.Provider, error) {
+	store := memory.NewArchivalStore(database)
+	cfg, err := config.Load("")
+	if err != nil {
+		return store, nil, nil
+	}
+	q, err := embeddings.ParseQuantization(cfg.Embeddings.Quantization)
+	if err != nil {
+		return nil, nil, err
+	}
+	store = store.WithQuantization(q)
+	embedProv, err := newEmbedProvider(cfg.Embeddings)
+	if err != nil || embedProv == nil {
+		return store, nil, nil
+	}
+	return store.WithEmbeddingModel(embedProv.ModelID()), cacheEmbeddings(embedProv, database), nil
+}
+
+// documentFiles expands add-file arguments into the files they name: a
+// glob's matches, a directory's markdown and text files, or the path itself.
+func documentFiles(args []string) ([]string, error) {
+	seen := map[string]bool{}
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(m)
+				continue
+			}
+			err = filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() && path != m && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				if _, ok := documentExts[strings.ToLower(filepath.Ext(path))]; ok && !d.IsDir() {
+					add(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// documentExts are the file types add-file picks up from a directory; the
+// value says whether the type is markdown.
+var documentExts = map[string]bool{".md": true, ".markdown": true, ".mdx": true, ".txt": false, ".text": false}
+
+// chunkFile reads a text file and splits it into chunks, by markdown
+// structure for markdown files and by paragraph otherwise.
+func chunkFile(path string, size int) ([]*memory.SourceChunk, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return nil, fmt.Errorf("%s is not a text file", path)
+	}
+	split := chunk.Text
+	if documentExts[strings.ToLower(filepath.Ext(path))] {
+		split = chunk.Markdown
+	}
+	var chunks []*memory.SourceChunk
+	for _, c := range split(string(data), size) {
+		chunks = append(chunks, &memory.SourceChunk{Content: c.Text, Offset: c.Offset})
+	}
+	return chunks, nil
+}
+
+// ftsQuery returns text as an FTS5 query: as is with --raw, else quoted as
+// plain text by memory.PlainQuery.
+func ftsQuery(cmd *cobra.Command, text string) string {
+	if raw, _ := cmd.Flags().GetBool("raw"); raw {
+		return text
+	}
+	return memory.PlainQuery(text)
+}
+
+// keywordMatches counts the search results that matched the query's words.
+// Full-text results all did; hybrid ones may have matched on their
+// embedding alone.
+func keywordMatches(results []*memory.SearchResult, hybrid bool) int {
+	if !hybrid {
+		return len(results)
+	}
+	n := 0
+	for _, r := range results {
+		if r.LexicalRank > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// queryError adds a pointer to the FTS5 syntax to a raw query's syntax
+// error.
+func queryError(err error) error {
+	if errors.Is(err, memory.ErrQuerySyntax) {
+		return fmt.Errorf("%w\nSee https://www.sqlite.org/fts5.html#full_text_query_syntax, or drop --raw to search for the text as typed", err)
+	}
+	return err
+}
+
+// parseID parses an archival entry ID argument.
+func parseID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", arg)
+	}
+	return id, nil
+}
+
+// dateRange reads the --since and --until flags. A date without a time
+// starts at local midnight; for --until the whole day is included.
+func dateRange(cmd *cobra.Command) (since, until time.Time, err error) {
+	if v, _ := cmd.Flags().GetString("since"); v != "" {
+		if since, _, err = parseDate(v); err != nil {
+			return since, until, err
+		}
+	}
+	if v, _ := cmd.Flags().GetString("until"); v != "" {
+		var dateOnly bool
+		if until, dateOnly, err = parseDate(v); err != nil {
+			return since, until, err
+		}
+		if dateOnly {
+			until = until.AddDate(0, 0, 1)
+		}
+	}
+	return since, until, nil
+}
+
+// parseDate parses an age relative to now (see parseAge), a YYYY-MM-DD date
+// in local time or an RFC 3339 timestamp, reporting whether it was a date.
+func parseDate(v string) (time.Time, bool, error) {
+	if age, err := parseAge(v); err == nil {
+		return time.Now().Add(-age), false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q — use an age like 7d, YYYY-MM-DD or an RFC 3339 timestamp", v)
+}
+
+// parseAge parses a duration in days ("7d") or weeks ("2w") as well as
+// anything time.ParseDuration accepts ("12h").
+func parseAge(v string) (time.Duration, error) {
+	day := 24 * time.Hour
+	for suffix, unit := range map[string]time.Duration{"d": day, "w": 7 * day} {
+		if n, ok := strings.CutSuffix(v, suffix); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("invalid duration %q", v)
+			}
+			return time.Duration(f * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q — use e.g. 30d, 2w or 12h", v)
+	}
+	return d, nil
+}
+
+// confirm asks a yes/no question on stdin; anything but y or yes is a no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// addFilterFlags adds the archival filter flags read by archiveFilter.
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("tag", nil, "only entries with all of these tags or their descendants (exact match)")
+	cmd.Flags().StringSlice("any-tag", nil, "only entries with at least one of these tags or their descendants")
+	cmd.Flags().String("since", "", "only entries created since this age (7d, 2w, 12h) or date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().String("until", "", "only entries created before this age or date (a YYYY-MM-DD date includes that day)")
+	cmd.Flags().StringArray("where", nil, "only entries whose metadata has key=value, or just the key (repeatable; dotted keys reach into objects)")
+	cmd.Flags().Bool("archived", false, "only entries archived by 'botmem forget', instead of live ones")
+}
+
+// archiveFilter builds the archival filter from addFilterFlags' flags.
+func archiveFilter(cmd *cobra.Command) (memory.Filter, error) {
+	var f memory.Filter
+	f.Tags.All, _ = cmd.Flags().GetStringSlice("tag")
+	f.Tags.Any, _ = cmd.Flags().GetStringSlice("any-tag")
+	f.Archived, _ = cmd.Flags().GetBool("archived")
+	where, _ := cmd.Flags().GetStringArray("where")
+	for _, w := range where {
+		m, err := memory.ParseMetaMatch(w)
+		if err != nil {
+			return f, err
+		}
+		f.Where = append(f.Where, m)
+	}
+	var err error
+	f.Since, f.Until, err = dateRange(cmd)
+	return f, err
+}
+
+// addMetadataFlags adds the flags read by metadataFlags.
+func addMetadataFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("meta", nil, "set a metadata field, key=value (repeatable; numbers, true/false and JSON values are kept as such)")
+	cmd.Flags().String("metadata", "", "metadata as a JSON object")
+	cmd.Flags().String("author", "", "shorthand for --meta author=<name>")
+}
+
+// metadataFlags applies addMetadataFlags' flags to current: --metadata
+// replaces it, then each --meta key=value sets a field and key= removes
+// one. It returns nil when the result is empty.
+func metadataFlags(cmd *cobra.Command, current memory.Metadata) (memory.Metadata, error) {
+	meta := memory.Metadata{}
+	for k, v := range current {
+		meta[k] = v
+	}
+	if raw, _ := cmd.Flags().GetString("metadata"); cmd.Flags().Changed("metadata") {
+		meta = memory.Metadata{}
+		if strings.TrimSpace(raw) != "" {
+			if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+				return nil, fmt.Errorf("--metadata must be a JSON object: %w", err)
+			}
+		}
+	}
+	fields, _ := cmd.Flags().GetStringArray("meta")
+	if author, _ := cmd.Flags().GetString("author"); cmd.Flags().Changed("author") {
+		fields = append(fields, "author="+author)
+	}
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			return nil, fmt.Errorf("invalid --meta %q: want key=value", f)
+		}
+		if v == "" {
+			delete(meta, k)
+			continue
+		}
+		meta[k] = memory.ParseMetaValue(v)
+	}
+	if len(meta) == 0 {
+		return nil, nil
+	}
+	return meta, nil
+}
+
+// metaLabel renders an entry's source and metadata for one-line text
+// output, or "" if it has neither.
+func metaLabel(e *memory.ArchivalEntry) string {
+	var parts []string
+	if e.Source != "" {
+		parts = append(parts, "source="+e.Source)
+	}
+	if len(e.Metadata) > 0 {
+		parts = append(parts, e.Metadata.String())
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " {" + strings.Join(parts, ", ") + "}"
+}
+
+func tagCmd() *cobra.Command {
+	cmd := &cobra.Command{Use: "tag", Short: "Manage archival tags"}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Show the tag tree with the number of entries under each tag",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			asJSON, _ := cmd.Flags().GetBool("json")
+			store := memory.NewTagStore(database)
+			if flat, _ := cmd.Flags().GetBool("flat"); flat {
+				tags, err := store.List()
+				if err != nil {
+					return err
+				}
+				if asJSON {
+					return printJSON(tags)
+				}
+				for _, t := range tags {
+					fmt.Printf("%5d  %s\n", t.Count, t.Name)
+				}
+				if len(tags) == 0 {
+					fmt.Println("No tags.")
+				}
+				return nil
+			}
+
+			tree, err := store.Tree()
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				return printJSON(tree)
+			}
+			printTagTree(tree, 0)
+			if len(tree) == 0 {
+				fmt.Println("No tags.")
+			}
+			return nil
+		},
+	}
+	list.Flags().Bool("flat", false, "list full tag names by usage instead of as a tree")
+	list.Flags().Bool("json", false, "output as JSON")
+	cmd.AddCommand(list)
+
 	cmd.AddCommand(&cobra.Command{
-		Use:   "add <text> [--tags tag1,tag2]",
-		Short: "Add an archival entry",
+		Use:   "rename <tag> <new-name>",
+		Short: "Rename a tag, and its descendants, on every entry",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			n, err := memory.NewTagStore(database).Rename(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Renamed %q to %q on %d entries\n", args[0], args[1], n)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "merge <tag>... <into>",
+		Short: "Merge one or more tags, and their descendants, into another",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			into, sources := args[len(args)-1], args[:len(args)-1]
+			n, err := memory.NewTagStore(database).Merge(into, sources...)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Merged %s into %q on %d entries\n", strings.Join(sources, ", "), into, n)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <tag>",
+		Short: "Remove a tag and its descendants from every entry (the entries are kept)",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			database, err := db.Open(dbPath)
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			n, err := memory.NewTagStore(database).Delete(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %q from %d entries\n", args[0], n)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// printTagTree prints tag nodes indented by depth, each with the number of
+// entries under it.
+func printTagTree(nodes []*memory.TagNode, depth int) {
+	for _, n := range nodes {
+		fmt.Printf("%5d  %s%s\n", n.Total, strings.Repeat("  ", depth), n.Name)
+		printTagTree(n.Children, depth+1)
+	}
+}
+
+func dbCmd() *cobra.Command {
+	cmd := &cobra.Command{Use: "db", Short: "Database maintenance"}
+
+	migrate := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending schema migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Open without migrating so --status reports what is pending
+			// rather than applying it first.
+			database, err := db.OpenNoMigrate(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if statusOnly, _ := cmd.Flags().GetBool("status"); !statusOnly {
+				if err := db.Migrate(database); err != nil {
+					return fmt.Errorf("migrate: %w", err)
+				}
+				v, err := db.CurrentVersion(database)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Schema is at version %d.\n", v)
+				return nil
+			}
+
+			statuses, err := db.Status(database)
+			if err != nil {
+				return err
+			}
+			for _, st := range statuses {
+				applied := "pending"
+				if st.AppliedAt != nil {
+					applied = "applied " + st.AppliedAt.Format("2006-01-02 15:04")
+				}
+				fmt.Printf("%3d  %-24s %s\n", st.Version, st.Name, applied)
+			}
+			return nil
+		},
+	}
+	migrate.Flags().Bool("status", false, "list migrations and when each was applied")
+	cmd.AddCommand(migrate)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "tokenizer [" + strings.Join(db.Tokenizers, "|") + "]",
+		Short: "Show the full-text search tokenizer, or rebuild the index with another",
+		Long: `Show or change how archival entries are tokenized for full-text search.
+
+  unicode61  words split on spaces and punctuation, case and accents folded (default)
+  porter     unicode61 plus English stemming: "contracts" finds "contract"
+  trigram    three-character sequences: matches inside words and in text
+             without spaces (Chinese, Japanese); terms need 3+ characters
+
+Changing the tokenizer rebuilds the full-text index from every entry.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				return setTokenizer(args[0])
+			}
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+			name, err := db.Tokenizer(database)
+			if err != nil {
+				return err
+			}
+			fmt.Println(name)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// setTokenizer rebuilds the full-text index with the named tokenizer, unless
+// it already uses it.
+func setTokenizer(name string) error {
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if current, err := db.Tokenizer(database); err != nil {
+		return err
+	} else if current == name {
+		fmt.Printf("Full-text search already uses the %s tokenizer.\n", name)
+		return nil
+	}
+	if err := db.SetTokenizer(database, name); err != nil {
+		return err
+	}
+	fmt.Printf("Rebuilt the full-text index with the %s tokenizer.\n", name)
+	return nil
+}
+
+func embeddingsCmd() *cobra.Command {
+	cmd := &cobra.Command{Use: "embeddings", Short: "Embedding maintenance"}
+
+	cache := &cobra.Command{
+		Use:   "cache",
+		Short: "Show or clear the embedding cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			c := memory.NewEmbeddingCache(database)
+			if clear, _ := cmd.Flags().GetBool("clear"); clear {
+				n, err := c.Clear()
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Cleared %d cached embeddings.\n", n)
+				return nil
+			}
+			counts, err := c.Counts()
+			if err != nil {
+				return err
+			}
+			for _, mc := range counts {
+				fmt.Printf("%-40s %d\n", mc.Model, mc.Count)
+			}
+			if len(counts) == 0 {
+				fmt.Println("Embedding cache is empty.")
+			}
+			return nil
+		},
+	}
+	cache.Flags().Bool("clear", false, "delete every cached embedding")
+	cmd.AddCommand(cache)
+
+	reembed := &cobra.Command{
+		Use:   "reembed",
+		Short: "Embed entries that have no embedding or one from a different model",
+		Long: `Backfills missing embeddings and replaces those produced by any model other
+than the configured one (or --model). Progress is committed batch by batch,
+so an interrupted run resumes where it stopped when re-run.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load("")
+			if err != nil {
+				return err
+			}
+			if !cfg.Embeddings.Enabled {
+				return fmt.Errorf("embeddings are not enabled — run 'botmem init' to enable them")
+			}
+			embCfg := cfg.Embeddings
+			model, _ := cmd.Flags().GetString("model")
+			if model != "" {
+				if embCfg.Provider == "local" {
+					return fmt.Errorf("--model does not apply to the built-in embeddings provider")
+				}
+				embCfg.Model = model
+			}
+			embedProv, err := newEmbedProvider(embCfg)
+			if err != nil {
+				return err
+			}
+			quant, err := embeddings.ParseQuantization(embCfg.Quantization)
+			if err != nil {
+				return err
+			}
+			batchSize, _ := cmd.Flags().GetInt("batch")
+			if batchSize <= 0 {
+				batchSize = 64
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			embedProv = cacheEmbeddings(embedProv, database)
+			modelID := embedProv.ModelID()
+			store := memory.NewArchivalStore(database).WithEmbeddingModel(modelID)
+			total, err := store.CountStaleEmbeddings()
+			if err != nil {
+				return err
+			}
+
+			done := 0
+			var after int64
+			for done < total {
+				entries, err := store.StaleEmbeddings(after, batchSize)
+				if err != nil {
+					return err
+				}
+				if len(entries) == 0 {
+					break
+				}
+				texts := make([]string, len(entries))
+				for i, e := range entries {
+					texts[i] = e.Content
+				}
+				vecs, err := embedProv.BatchEmbed(texts)
+				if err != nil {
+					fmt.Fprintln(os.Stderr)
+					return fmt.Errorf("embed after %d/%d entries (re-run to resume): %w", done, total, err)
+				}
+				if err := setEmbeddings(database, modelID, quant, entries, vecs); err != nil {
+					return err
+				}
+				after = entries[len(entries)-1].ID
+				done += len(entries)
+				fmt.Fprintf(os.Stderr, "\rRe-embedded %d/%d entries", done, total)
+			}
+			if done > 0 {
+				fmt.Fprintln(os.Stderr)
+			}
+			fmt.Printf("All entries are embedded with %s.\n", modelID)
+
+			if model != "" && model != cfg.Embeddings.Model {
+				cfg.Embeddings.Model = model
+				if err := config.Save(cfg, ""); err != nil {
+					return fmt.Errorf("save config: %w", err)
+				}
+				fmt.Printf("Set embeddings model to %s in config.\n", model)
+			}
+			if st, err := memory.NewANNIndex(database).Status(); err == nil && st.Built && st.Stale {
+				fmt.Println("The ANN index is stale — run 'botmem reindex'.")
+			}
+			return nil
+		},
+	}
+	reembed.Flags().String("model", "", "embedding model to use instead of the configured one (saved to config on success)")
+	reembed.Flags().Int("batch", 64, "entries per embedding request")
+	cmd.AddCommand(reembed)
+
+	quantize := &cobra.Command{
+		Use:   "quantize",
+		Short: "Convert stored embeddings to the configured encoding (float32, int8 or binary)",
+		Long: `Re-encodes every stored embedding in embeddings.quantization from the config,
+or in --to (which is then saved to the config). Converting to int8 cuts
+embedding storage by about 75% and binary by about 97%; converting back to
+float32 does not recover the precision that quantizing discarded. The
+database is vacuumed afterwards to reclaim the space.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load("")
+			if err != nil {
+				return err
+			}
+			target := cfg.Embeddings.Quantization
+			if cmd.Flags().Changed("to") {
+				target, _ = cmd.Flags().GetString("to")
+			}
+			quant, err := embeddings.ParseQuantization(target)
 			if err != nil {
 				return err
 			}
-			defer database.Close()
 
-			tagsFlag, _ := cmd.Flags().GetString("tags")
-			var tags []string
-			if tagsFlag != "" {
-				tags = strings.Split(tagsFlag, ",")
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
 			}
+			defer database.Close()
 
-			e, err := memory.NewArchivalStore(database).Add(args[0], tags, nil)
+			store := memory.NewArchivalStore(database).WithQuantization(quant)
+			total, err := store.CountToRequantize()
 			if err != nil {
 				return err
 			}
-			fmt.Printf("Added archival entry (id=%d)\n", e.ID)
+			done := 0
+			var after int64
+			for done < total {
+				n, last, err := store.Requantize(after, 512)
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					break
+				}
+				done, after = done+n, last
+				fmt.Fprintf(os.Stderr, "\rConverted %d/%d embeddings", done, total)
+			}
+			if done > 0 {
+				fmt.Fprintln(os.Stderr)
+				if _, err := database.Exec(`VACUUM`); err != nil {
+					return fmt.Errorf("vacuum: %w", err)
+				}
+			}
+			fmt.Printf("All embeddings are stored as %s.\n", quant)
+
+			if string(quant) != cfg.Embeddings.Quantization && cmd.Flags().Changed("to") {
+				cfg.Embeddings.Quantization = string(quant)
+				if err := config.Save(cfg, ""); err != nil {
+					return fmt.Errorf("save config: %w", err)
+				}
+				fmt.Printf("Set embeddings quantization to %s in config.\n", quant)
+			}
 			return nil
 		},
-	})
-	cmd.Commands()[0].Flags().String("tags", "", "comma-separated tags")
+	}
+	quantize.Flags().String("to", "", "encoding to convert to: float32, int8, or binary (default: the configured one)")
+	cmd.AddCommand(quantize)
 
-	cmd.AddCommand(&cobra.Command{
-		Use:   "search <query>",
-		Short: "Search archival memory (full-text)",
-		Args:  cobra.ExactArgs(1),
+	return cmd
+}
+
+// setEmbeddings stores one batch of re-embedded entries in a transaction.
+func setEmbeddings(database *sql.DB, modelID string, quant embeddings.Quantization, entries []*memory.ArchivalEntry, vecs [][]float32) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	store := memory.NewArchivalStore(tx).WithEmbeddingModel(modelID).WithQuantization(quant)
+	for i, e := range entries {
+		if err := store.SetEmbedding(e.ID, embeddings.SerializeEmbedding(vecs[i])); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func forgetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "forget [--policy name]",
+		Short: "Archive or delete low-importance memories that have gone unaccessed, per a decay policy",
+		Long: `Forget archival facts and graph relations whose retention has decayed
+below the policy's threshold. A memory's retention is its importance (0-1)
+times a decay factor that falls with the time since it was last accessed:
+by half every half-life on the exponential curve, or in a straight line to
+zero at twice the half-life on the linear one. Each access stretches the
+half-life, so memories recalled often fade slower. Memories accessed within
+the policy's minimum idle time, and memories of importance 1, are kept.
+
+Policies are named under forget.policies in config.yaml; "default" is used
+unless --policy picks another, and flags override single settings. botmem
+lists what would be forgotten and asks before doing it, unless --yes is
+given. Archived memories are hidden from search, listings and context until
+'botmem forget restore' brings them back.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			policy, err := forgetPolicy(cmd)
+			if err != nil {
+				return err
+			}
+
 			database, err := db.Open(dbPath)
 			if err != nil {
 				return err
 			}
 			defer database.Close()
 
-			entries, err := memory.NewArchivalStore(database).Search(args[0], 10)
+			store := memory.NewForgetStore(database)
+			forget, err := store.Plan(policy, time.Now())
 			if err != nil {
 				return err
 			}
-			for _, e := range entries {
-				fmt.Printf("[%d] %s (tags: %s)\n", e.ID, e.Content, e.Tags)
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON && dryRun {
+				return printJSON(forget)
 			}
-			if len(entries) == 0 {
-				fmt.Println("No results.")
+			if len(forget) == 0 {
+				fmt.Println("Nothing to forget.")
+				return nil
+			}
+			for i, m := range forget {
+				if i < 10 || dryRun {
+					fmt.Printf("%s %d: %s (importance %.2g, retention %.2g)\n",
+						m.Kind, m.ID, truncate(m.Text, 70), m.Importance, m.Retention)
+				}
+			}
+			if len(forget) > 10 && !dryRun {
+				fmt.Printf("... and %d more\n", len(forget)-10)
+			}
+			if dryRun {
+				return nil
+			}
+			verb := strings.ToUpper(string(policy.Action[:1])) + string(policy.Action[1:])
+			if yes, _ := cmd.Flags().GetBool("yes"); !yes && !confirm(fmt.Sprintf("%s %d memories?", verb, len(forget))) {
+				fmt.Println("Aborted.")
+				return nil
+			}
+			if err := store.Forget(policy.Action, forget); err != nil {
+				return err
+			}
+			if policy.Action == memory.ForgetArchive {
+				fmt.Printf("Archived %d memories (botmem forget restore brings them back).\n", len(forget))
+			} else {
+				fmt.Printf("Deleted %d memories.\n", len(forget))
 			}
 			return nil
 		},
-	})
-
-	cmd.AddCommand(&cobra.Command{
+	}
+	cmd.Flags().String("policy", "default", "policy to apply, from forget.policies in config.yaml")
+	cmd.Flags().String("action", "", "override the policy's action: archive or delete")
+	cmd.Flags().String("curve", "", "override the policy's decay curve: exponential or linear")
+	cmd.Flags().String("half-life", "", "override the policy's half-life (e.g. 90d)")
+	cmd.Flags().Float64("threshold", 0, "override the retention below which memories are forgotten (0-1)")
+	cmd.Flags().String("min-idle", "", "override how long a memory must go unaccessed before it can be forgotten (e.g. 30d)")
+	cmd.Flags().Bool("dry-run", false, "list what would be forgotten, with retention scores, and change nothing")
+	cmd.Flags().Bool("json", false, "with --dry-run, output the memories as JSON")
+	cmd.Flags().BoolP("yes", "y", false, "don't ask for confirmation")
+
+	list := &cobra.Command{
 		Use:   "list",
-		Short: "List archival entries",
+		Short: "List archived memories",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			database, err := db.Open(dbPath)
 			if err != nil {
@@ -214,19 +1610,162 @@ func archiveCmd() *cobra.Command {
 			}
 			defer database.Close()
 
-			tag, _ := cmd.Flags().GetString("tag")
-			entries, err := memory.NewArchivalStore(database).List(tag, 50)
+			archived, err := memory.NewForgetStore(database).Archived()
 			if err != nil {
 				return err
 			}
-			for _, e := range entries {
-				fmt.Printf("[%d] %s (tags: %s)\n", e.ID, truncate(e.Content, 80), e.Tags)
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				return printJSON(archived)
+			}
+			for _, m := range archived {
+				fmt.Printf("%s %d: %s (archived %s)\n", m.Kind, m.ID, truncate(m.Text, 70), m.ArchivedAt.Local().Format("2006-01-02"))
+			}
+			if len(archived) == 0 {
+				fmt.Println("No archived memories.")
 			}
 			return nil
 		},
-	})
-	cmd.Commands()[1].Flags().String("tag", "", "filter by tag")
+	}
+	list.Flags().Bool("json", false, "output as JSON")
+	cmd.AddCommand(list)
+
+	restore := &cobra.Command{
+		Use:   "restore [fact-id...] [--relation id...] [--all]",
+		Short: "Bring archived facts and relations back",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all")
+			relations, _ := cmd.Flags().GetInt64Slice("relation")
+			if all && (len(args) > 0 || len(relations) > 0) {
+				return fmt.Errorf("give IDs or --all, not both")
+			}
+			if !all && len(args) == 0 && len(relations) == 0 {
+				return fmt.Errorf("give fact IDs, --relation IDs or --all")
+			}
+			var facts []int64
+			for _, arg := range args {
+				id, err := parseID(arg)
+				if err != nil {
+					return err
+				}
+				facts = append(facts, id)
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			store := memory.NewForgetStore(database)
+			var nFacts, nRelations int
+			if all || len(facts) > 0 {
+				if nFacts, err = store.Restore(memory.KindFact, facts...); err != nil {
+					return err
+				}
+			}
+			if all || len(relations) > 0 {
+				if nRelations, err = store.Restore(memory.KindRelation, relations...); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Restored %d facts and %d relations.\n", nFacts, nRelations)
+			return nil
+		},
+	}
+	restore.Flags().Int64Slice("relation", nil, "relation IDs to restore (see 'botmem forget list')")
+	restore.Flags().Bool("all", false, "restore every archived memory")
+	cmd.AddCommand(restore)
+
+	return cmd
+}
+
+// forgetPolicy builds the policy named by --policy from the built-in
+// default, the config and the override flags.
+func forgetPolicy(cmd *cobra.Command) (memory.ForgetPolicy, error) {
+	policy := memory.DefaultForgetPolicy
+	name, _ := cmd.Flags().GetString("policy")
+	var pc config.ForgetPolicyConfig
+	if cfg, err := config.Load(""); err == nil {
+		var ok bool
+		if pc, ok = cfg.Forget.Policies[name]; !ok && name != "default" {
+			return policy, fmt.Errorf("no forget policy %q in config.yaml", name)
+		}
+	} else if name != "default" {
+		return policy, err
+	}
+
+	for flag, field := range map[string]*string{
+		"action":    &pc.Action,
+		"curve":     &pc.Curve,
+		"half-life": &pc.HalfLife,
+		"min-idle":  &pc.MinIdle,
+	} {
+		if cmd.Flags().Changed(flag) {
+			*field, _ = cmd.Flags().GetString(flag)
+		}
+	}
+	if pc.Action != "" {
+		policy.Action = memory.ForgetAction(pc.Action)
+	}
+	if pc.Curve != "" {
+		policy.Curve = memory.DecayCurve(pc.Curve)
+	}
+	if pc.Threshold != 0 {
+		policy.Threshold = pc.Threshold
+	}
+	if cmd.Flags().Changed("threshold") {
+		policy.Threshold, _ = cmd.Flags().GetFloat64("threshold")
+	}
+	var err error
+	if pc.HalfLife != "" {
+		if policy.HalfLife, err = parseAge(pc.HalfLife); err != nil {
+			return policy, err
+		}
+	}
+	if pc.MinIdle != "" {
+		if policy.MinIdle, err = parseAge(pc.MinIdle); err != nil {
+			return policy, err
+		}
+	}
+	return policy, policy.Validate()
+}
+
+func reindexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the approximate-nearest-neighbour index used by semantic search",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			index := memory.NewANNIndex(database)
+			var st *memory.ANNStatus
+			if statusOnly, _ := cmd.Flags().GetBool("status"); statusOnly {
+				st, err = index.Status()
+			} else {
+				st, err = index.Rebuild()
+			}
+			if err != nil {
+				return err
+			}
 
+			if !st.Built {
+				fmt.Printf("No ANN index (%d embedded entries) — semantic search uses a full scan.\n", st.Embedded)
+				return nil
+			}
+			state := "fresh"
+			if st.Stale {
+				state = "stale — run 'botmem reindex'"
+			}
+			fmt.Printf("ANN index: %d lists, %d/%d entries indexed, dim %d, built %s (%s)\n",
+				st.Lists, st.Indexed, st.Embedded, st.Dim, st.BuiltAt.Format("2006-01-02 15:04"), state)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("status", false, "show index status without rebuilding")
 	return cmd
 }
 
@@ -244,13 +1783,22 @@ func graphCmd() *cobra.Command {
 			}
 			defer database.Close()
 
-			if err := memory.NewGraphStore(database).AddRelation(args[0], args[1], args[2], ""); err != nil {
+			graph := memory.NewGraphStore(database)
+			id, _, err := graph.EnsureRelation(args[0], args[1], args[2], "")
+			if err != nil {
 				return err
 			}
+			if cmd.Flags().Changed("importance") {
+				importance, _ := cmd.Flags().GetFloat64("importance")
+				if err := graph.SetImportance(id, importance); err != nil {
+					return err
+				}
+			}
 			fmt.Printf("Added: %s -[%s]-> %s\n", args[0], args[1], args[2])
 			return nil
 		},
 	})
+	cmd.Commands()[0].Flags().Float64("importance", memory.DefaultImportance, "how much the relation matters, from 0 to 1 (1: never forget)")
 
 	cmd.AddCommand(&cobra.Command{
 		Use:   "query <entity>",
@@ -268,7 +1816,7 @@ func graphCmd() *cobra.Command {
 				return err
 			}
 			for _, r := range rels {
-				fmt.Printf("%s -[%s]-> %s\n", r.Subject, r.Predicate, r.Object)
+				fmt.Printf("%s -[%s]-> %s%s\n", r.Subject, r.Predicate, r.Object, runLabel(r.RunID))
 			}
 			if len(rels) == 0 {
 				fmt.Println("No relations found.")
@@ -293,7 +1841,7 @@ func graphCmd() *cobra.Command {
 				return err
 			}
 			for _, r := range rels {
-				fmt.Printf("%s -[%s]-> %s\n", r.Subject, r.Predicate, r.Object)
+				fmt.Printf("%s -[%s]-> %s%s\n", r.Subject, r.Predicate, r.Object, runLabel(r.RunID))
 			}
 			return nil
 		},
@@ -417,27 +1965,41 @@ Non-interactive (for agents/scripts):
   botmem init --provider claude
   botmem init --provider anthropic --api-key sk-ant-...
   botmem init --provider anthropic  # uses ANTHROPIC_API_KEY env var
-  botmem init --provider ollama --model llama3.2 --url http://localhost:11434`,
+  botmem init --provider ollama --model llama3.2 --url http://localhost:11434
+  botmem init --provider openai --url http://localhost:8080/v1 --model qwen2.5-7b-instruct
+  botmem init --provider claude --tokenizer trigram`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			provider, _ := cmd.Flags().GetString("provider")
 
 			// If --provider is set, run non-interactive init
+			var err error
 			if provider != "" {
-				return runNonInteractiveInit(cmd)
+				err = runNonInteractiveInit(cmd)
+			} else {
+				// Otherwise, run the TUI
+				_, err = config.RunInitTUI()
+			}
+			if err != nil {
+				return err
 			}
 
-			// Otherwise, run the TUI
-			_, err := config.RunInitTUI()
-			return err
+			if tokenizer, _ := cmd.Flags().GetString("tokenizer"); tokenizer != "" {
+				return setTokenizer(tokenizer)
+			}
+			return nil
 		},
 	}
-	cmd.Flags().String("provider", "", "LLM provider: claude, anthropic, or ollama")
-	cmd.Flags().String("api-key", "", "API key (for anthropic provider)")
+	cmd.Flags().String("provider", "", "LLM provider: claude, anthropic, ollama, or openai (any OpenAI-compatible server)")
+	cmd.Flags().String("api-key", "", "API key (for anthropic or openai providers)")
 	cmd.Flags().String("model", "", "Model name (default varies by provider)")
-	cmd.Flags().String("url", "", "Base URL (for ollama provider)")
-	cmd.Flags().Bool("embeddings", false, "Enable semantic embeddings (requires Ollama)")
-	cmd.Flags().String("embeddings-model", "nomic-embed-text", "Embedding model")
-	cmd.Flags().String("embeddings-url", "http://localhost:11434", "Ollama URL for embeddings")
+	cmd.Flags().String("url", "", "Base URL (for ollama or openai providers)")
+	cmd.Flags().Bool("embeddings", false, "Enable semantic embeddings")
+	cmd.Flags().String("embeddings-provider", "ollama", "Embeddings provider: ollama, openai (any /v1/embeddings server), or local (built-in, offline)")
+	cmd.Flags().String("embeddings-model", "", "Embedding model (default nomic-embed-text for ollama)")
+	cmd.Flags().String("embeddings-url", "", "Embeddings server URL (default http://localhost:11434 for ollama, http://localhost:8080/v1 for openai)")
+	cmd.Flags().String("embeddings-api-key", "", "API key for the embeddings server (openai provider)")
+	cmd.Flags().Int("embeddings-dimensions", 0, "Embedding size (local provider, default 384; openai, if the model supports it)")
+	cmd.Flags().String("tokenizer", "", "Full-text search tokenizer: "+strings.Join(db.Tokenizers, ", ")+" (see 'botmem db tokenizer')")
 	return cmd
 }
 
@@ -447,8 +2009,11 @@ func runNonInteractiveInit(cmd *cobra.Command) error {
 	model, _ := cmd.Flags().GetString("model")
 	baseURL, _ := cmd.Flags().GetString("url")
 	enableEmb, _ := cmd.Flags().GetBool("embeddings")
+	embProvider, _ := cmd.Flags().GetString("embeddings-provider")
 	embModel, _ := cmd.Flags().GetString("embeddings-model")
 	embURL, _ := cmd.Flags().GetString("embeddings-url")
+	embKey, _ := cmd.Flags().GetString("embeddings-api-key")
+	embDims, _ := cmd.Flags().GetInt("embeddings-dimensions")
 
 	cfg := &config.Config{}
 
@@ -459,26 +2024,55 @@ func runNonInteractiveInit(cmd *cobra.Command) error {
 	case "anthropic":
 		cfg.LLM.Provider = "anthropic"
 		if model == "" {
-			model = "claude-sonnet-4-20250514"
+			model = llm.DefaultAnthropicModel
 		}
 		cfg.LLM.Model = model
 		cfg.LLM.APIKey = apiKey // empty is fine — will use ANTHROPIC_API_KEY env var
 	case "ollama":
 		cfg.LLM.Provider = "ollama"
 		if model == "" {
-			model = "llama3.2"
+			model = llm.DefaultOllamaModel
 		}
 		cfg.LLM.Model = model
 		if baseURL == "" {
-			baseURL = "http://localhost:11434"
+			baseURL = llm.DefaultOllamaURL
+		}
+		cfg.LLM.BaseURL = baseURL
+	case "openai":
+		cfg.LLM.Provider = "openai"
+		cfg.LLM.Model = model // empty is fine for single-model servers
+		if baseURL == "" {
+			baseURL = llm.DefaultOpenAIURL
 		}
 		cfg.LLM.BaseURL = baseURL
+		cfg.LLM.APIKey = apiKey // empty is fine — will use OPENAI_API_KEY env var, if any
 	default:
-		return fmt.Errorf("unknown provider %q — use claude, anthropic, or ollama", provider)
+		return fmt.Errorf("unknown provider %q — use %s", provider, strings.Join(llm.Providers, ", "))
 	}
 
 	if enableEmb {
+		switch embProvider {
+		case "ollama":
+			if embModel == "" {
+				embModel = "nomic-embed-text"
+			}
+			if embURL == "" {
+				embURL = "http://localhost:11434"
+			}
+		case "openai":
+			if embURL == "" {
+				embURL = "http://localhost:8080/v1"
+			}
+			cfg.Embeddings.APIKey = embKey
+			cfg.Embeddings.Dimensions = embDims
+		case "local":
+			embModel, embURL = embeddings.LocalModel, ""
+			cfg.Embeddings.Dimensions = embDims
+		default:
+			return fmt.Errorf("unknown embeddings provider %q — use ollama, openai, or local", embProvider)
+		}
 		cfg.Embeddings.Enabled = true
+		cfg.Embeddings.Provider = embProvider
 		cfg.Embeddings.Model = embModel
 		cfg.Embeddings.BaseURL = embURL
 	}
@@ -494,24 +2088,48 @@ func runNonInteractiveInit(cmd *cobra.Command) error {
 	return nil
 }
 
+// newEmbedProvider builds the embeddings provider described by cfg, or
+// returns nil if embeddings are disabled.
+func newEmbedProvider(cfg config.EmbeddingsConfig) (embeddings.Provider, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	return embeddings.New(cfg.Provider, cfg.BaseURL, cfg.Model, cfg.APIKey, cfg.Dimensions)
+}
+
+// cacheEmbeddings serves prov's embeddings from the database's embedding
+// cache where possible.
+func cacheEmbeddings(prov embeddings.Provider, database *sql.DB) embeddings.Provider {
+	return embeddings.NewCachedProvider(prov, memory.NewEmbeddingCache(database))
+}
+
 func loadIngestConfig() (*ingest.Config, error) {
 	cfg, err := config.Load("")
 	if err != nil {
 		return nil, err
 	}
 
-	var embedProv embeddings.Provider
-	if cfg.Embeddings.Enabled {
-		embedProv = embeddings.NewOllamaProvider(cfg.Embeddings.BaseURL, cfg.Embeddings.Model)
+	embedProv, err := newEmbedProvider(cfg.Embeddings)
+	if err != nil {
+		return nil, err
+	}
+	quant, err := embeddings.ParseQuantization(cfg.Embeddings.Quantization)
+	if err != nil {
+		return nil, err
 	}
 
-	return ingest.ConfigFromAppConfig(
+	ingestCfg, err := ingest.ConfigFromAppConfig(
 		cfg.LLM.Provider,
 		cfg.LLM.Model,
 		cfg.LLM.APIKey,
 		cfg.LLM.BaseURL,
 		embedProv,
-	), nil
+	)
+	if err != nil {
+		return nil, err
+	}
+	ingestCfg.Quant = quant
+	return ingestCfg, nil
 }
 
 func ingestCmd() *cobra.Command {
@@ -524,6 +2142,10 @@ func ingestCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			cfg.Source, _ = cmd.Flags().GetString("source")
+			if cfg.Metadata, err = metadataFlags(cmd, nil); err != nil {
+				return err
+			}
 
 			database, err := db.Open(dbPath)
 			if err != nil {
@@ -546,6 +2168,7 @@ func ingestCmd() *cobra.Command {
 				return fmt.Errorf("no text provided")
 			}
 
+			cfg.EmbedProv = cacheEmbeddings(cfg.EmbedProv, database)
 			result, err := ingest.Run(database, text, cfg)
 			if err != nil {
 				return err
@@ -556,13 +2179,197 @@ func ingestCmd() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().String("source", "", "record where the text came from (conversation ID, URL...) on the facts it adds")
+	addMetadataFlags(cmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List recent ingest runs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			runs, err := memory.NewRunStore(database).List(20)
+			if err != nil {
+				return err
+			}
+			for _, r := range runs {
+				undone := ""
+				if r.UndoneAt != nil {
+					undone = " (undone)"
+				}
+				fmt.Printf("#%d %s %s/%s input:%s%s\n", r.ID, r.CreatedAt.Format("2006-01-02 15:04"), r.Provider, r.Model, truncate(r.InputHash, 12), undone)
+			}
+			if len(runs) == 0 {
+				fmt.Println("No ingest runs.")
+			}
+			return nil
+		},
+	})
+
+	show := &cobra.Command{
+		Use:   "show <run-id>",
+		Short: "Show what an ingest run produced",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runID, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid run id %q", args[0])
+			}
+
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			items, err := memory.NewRunStore(database).Items(runID)
+			if err != nil {
+				return err
+			}
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				return printJSON(items)
+			}
+			if raw, _ := cmd.Flags().GetBool("raw"); raw {
+				fmt.Println(items.Run.RawOutput)
+				return nil
+			}
+
+			r := items.Run
+			fmt.Printf("Run #%d  %s  %s/%s\n", r.ID, r.CreatedAt.Format("2006-01-02 15:04"), r.Provider, r.Model)
+			fmt.Printf("Input sha256: %s\n", r.InputHash)
+			if len(items.BlockChanges) > 0 {
+				fmt.Println("\nBlock changes:")
+				for _, c := range items.BlockChanges {
+					action := "updated"
+					if c.PreviousContent == nil {
+						action = "created"
+					}
+					fmt.Printf("  %s (%s): %s\n", c.Label, action, truncate(c.NewContent, 80))
+				}
+			}
+			if len(items.Facts) > 0 {
+				fmt.Println("\nFacts:")
+				for _, e := range items.Facts {
+					fmt.Printf("  [%d] %s (tags: %s)%s\n", e.ID, truncate(e.Content, 80), e.Tags, metaLabel(e))
+				}
+			}
+			if len(items.FactChanges) > 0 {
+				fmt.Println("\nFact changes:")
+				for _, c := range items.FactChanges {
+					if c.NewContent == nil {
+						fmt.Printf("  [%d] deleted: %s\n", c.ArchivalID, truncate(c.Previous.Content, 80))
+						continue
+					}
+					fmt.Printf("  [%d] updated: %s -> %s\n", c.ArchivalID, truncate(c.Previous.Content, 60), truncate(*c.NewContent, 60))
+				}
+			}
+			if len(items.Relations) > 0 {
+				fmt.Println("\nRelations:")
+				for _, rel := range items.Relations {
+					fmt.Printf("  %s -[%s]-> %s\n", rel.Subject, rel.Predicate, rel.Object)
+				}
+			}
+			if len(items.Summaries) > 0 {
+				fmt.Println("\nSummaries:")
+				for _, sm := range items.Summaries {
+					fmt.Printf("  [L%d #%d] %s\n", sm.Level, sm.ID, truncate(sm.Content, 100))
+				}
+			}
+			return nil
+		},
+	}
+	show.Flags().Bool("json", false, "output as JSON")
+	show.Flags().Bool("raw", false, "print the raw LLM output only")
+	cmd.AddCommand(show)
+
+	undo := &cobra.Command{
+		Use:   "undo [run-id]",
+		Short: "Revert an ingest run (default: the most recent one)",
+		Long: `Delete the facts, relations and summary created by an ingest run and
+restore the memory blocks it overwrote. Blocks edited since the run are left
+as they are.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath)
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			runs := memory.NewRunStore(database)
+			var runID int64
+			if len(args) > 0 {
+				if runID, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+					return fmt.Errorf("invalid run id %q", args[0])
+				}
+			} else {
+				latest, err := runs.Latest()
+				if err != nil {
+					return err
+				}
+				runID = latest.ID
+			}
+
+			res, err := runs.Undo(runID)
+			if err != nil {
+				return err
+			}
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				return printJSON(res)
+			}
+			fmt.Printf("Undid run #%d: deleted %d facts, %d relations, %d summaries.\n",
+				res.RunID, res.Facts, res.Relations, res.Summaries)
+			if res.FactsRestored > 0 {
+				fmt.Printf("  restored %d updated or deleted facts\n", res.FactsRestored)
+			}
+			for _, id := range res.FactsSkipped {
+				fmt.Printf("  skipped fact [%d] (changed since the run)\n", id)
+			}
+			for _, label := range res.BlocksRestored {
+				fmt.Printf("  restored block %q\n", label)
+			}
+			for _, label := range res.BlocksDeleted {
+				fmt.Printf("  deleted block %q (created by the run)\n", label)
+			}
+			for _, label := range res.BlocksSkipped {
+				fmt.Printf("  skipped block %q (changed since the run)\n", label)
+			}
+			return nil
+		},
+	}
+	undo.Flags().Bool("json", false, "output as JSON")
+	cmd.AddCommand(undo)
+
 	return cmd
 }
 
+// runLabel renders an ingest run reference for text output, or "" if the
+// item was not created by an ingest.
+func runLabel(runID *int64) string {
+	if runID == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (run #%d)", *runID)
+}
+
+func printJSON(v any) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
 	}
 	return s[:n] + "..."
 }
-